@@ -1,15 +1,21 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 	"tritontube/internal/proto"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func main() {
@@ -30,23 +36,90 @@ func main() {
 
 	switch cmd {
 	case "add":
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: add <server_address> <node_address>")
+		args, limits := throttleOption(os.Args)
+		args, plan := planOption(args)
+		if len(args) != 4 && len(args) != 5 {
+			fmt.Println("Usage: add <server_address> <node_address> [weight] [--plan|--plan=json] [limits]")
 			os.Exit(1)
 		}
-		addNode(client, os.Args[3])
+		weight := 1
+		if len(args) == 5 {
+			weight, err = strconv.Atoi(args[4])
+			if err != nil || weight < 1 {
+				fmt.Println("weight must be a positive integer")
+				os.Exit(1)
+			}
+		}
+		addNode(client, args[3], weight, plan, limits)
 	case "remove":
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: remove <server_address> <node_address>")
+		args, limits := throttleOption(os.Args)
+		args, plan := planOption(args)
+		if len(args) != 4 {
+			fmt.Println("Usage: remove <server_address> <node_address> [--plan|--plan=json] [limits]")
+			os.Exit(1)
+		}
+		removeNode(client, args[3], plan, limits)
+	case "drain", "undrain":
+		args, limits := throttleOption(os.Args)
+		if len(args) != 4 || (cmd == "undrain" && limits != nil) {
+			fmt.Printf("Usage: %s <server_address> <node_address>\n", cmd)
+			os.Exit(1)
+		}
+		drainNode(client, args[3], cmd == "undrain", limits)
+	case "rebalance":
+		args, limits := throttleOption(os.Args)
+		args, plan := planOption(args)
+		if len(args) != 3 {
+			fmt.Println("Usage: rebalance <server_address> [--plan|--plan=json] [limits]")
+			os.Exit(1)
+		}
+		rebalance(client, plan, limits)
+	case "throttle":
+		args, jobID := jobOption(os.Args)
+		if len(args) != 5 {
+			fmt.Println("Usage: throttle <server_address> [--job=<job_id>] <bytes_per_sec> <chunks_per_sec>")
 			os.Exit(1)
 		}
-		removeNode(client, os.Args[3])
+		setThrottle(client, jobID, &proto.Throttle{
+			BytesPerSecond:  parseRate("bytes_per_sec", args[3]),
+			ChunksPerSecond: parseRate("chunks_per_sec", args[4]),
+		})
 	case "list":
 		if len(os.Args) != 3 {
 			fmt.Println("Usage: list <server_address>")
 			os.Exit(1)
 		}
 		listNodes(client)
+	case "stats":
+		if len(os.Args) != 3 && (len(os.Args) != 4 || os.Args[3] != "--videos") {
+			fmt.Println("Usage: stats <server_address> [--videos]")
+			os.Exit(1)
+		}
+		clusterStats(client, len(os.Args) == 4)
+	case "jobs":
+		if len(os.Args) != 3 {
+			fmt.Println("Usage: jobs <server_address>")
+			os.Exit(1)
+		}
+		listJobs(client)
+	case "job":
+		if len(os.Args) != 4 {
+			fmt.Println("Usage: job <server_address> <job_id>")
+			os.Exit(1)
+		}
+		waitJob(client, os.Args[3], "done")
+	case "cancel":
+		if len(os.Args) != 4 && (len(os.Args) != 5 || os.Args[4] != "--rollback") {
+			fmt.Println("Usage: cancel <server_address> <job_id> [--rollback]")
+			os.Exit(1)
+		}
+		cancelJob(client, os.Args[3], len(os.Args) == 5)
+	case "resume":
+		if len(os.Args) != 4 {
+			fmt.Println("Usage: resume <server_address> <job_id>")
+			os.Exit(1)
+		}
+		resumeJob(client, os.Args[3])
 	default:
 		fmt.Printf("Unknown command: %s\n", cmd)
 		printUsageAndExit()
@@ -55,40 +128,141 @@ func main() {
 
 func printUsageAndExit() {
 	fmt.Println("Usage:")
-	fmt.Println("  add <server_address> <node_address>     - Add a node to the cluster")
-	fmt.Println("  remove <server_address> <node_address>  - Remove a node from the cluster")
-	fmt.Println("  list <server_address>                   - List all nodes in the cluster")
+	fmt.Println("  add <server_address> <node_address> [weight]  - Add a node to the cluster")
+	fmt.Println("  remove <server_address> <node_address>        - Remove a node from the cluster")
+	fmt.Println("  rebalance <server_address>                    - Move chunks that aren't on their owners, report duplicates and orphans")
+	fmt.Println("      --plan or --plan=json after add/remove/rebalance only prints the chunks that would move")
+	fmt.Println("  drain <server_address> <node_address>         - Stop writes to a node and copy its chunks off before removing it")
+	fmt.Println("  undrain <server_address> <node_address>       - Put a drained node back in write rotation")
+	fmt.Println("  list <server_address>                         - List all nodes in the cluster")
+	fmt.Println("  stats <server_address> [--videos]             - Show what each node stores next to its share of the ring")
+	fmt.Println("      --bytes-per-sec=<n> and --chunks-per-sec=<n> after add/remove/drain/rebalance limit the job's speed")
+	fmt.Println("  throttle <server_address> [--job=<job_id>] <bytes_per_sec> <chunks_per_sec>")
+	fmt.Println("                                                - Change the global or a job's migration speed limits, 0 for none")
+	fmt.Println("  jobs <server_address>                         - List migration jobs")
+	fmt.Println("  job <server_address> <job_id>                 - Follow a migration job until it ends")
+	fmt.Println("  cancel <server_address> <job_id> [--rollback] - Stop a migration job, or undo it with --rollback")
+	fmt.Println("  resume <server_address> <job_id>              - Run a failed or canceled migration job again")
 	os.Exit(1)
 }
 
-func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// planOption takes --plan or --plan=json out of the arguments, plan is "", "summary" or "json".
+func planOption(args []string) (rest []string, plan string) {
+	for _, arg := range args {
+		switch arg {
+		case "--plan":
+			plan = "summary"
+		case "--plan=json":
+			plan = "json"
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, plan
+}
+
+// throttleOption takes --bytes-per-sec=<n> and --chunks-per-sec=<n> out of the arguments,
+// limits is nil if neither is there.
+func throttleOption(args []string) (rest []string, limits *proto.Throttle) {
+	for _, arg := range args {
+		name, val, _ := strings.Cut(arg, "=")
+		switch name {
+		case "--bytes-per-sec":
+			limits = cmp.Or(limits, &proto.Throttle{})
+			limits.BytesPerSecond = parseRate(name, val)
+		case "--chunks-per-sec":
+			limits = cmp.Or(limits, &proto.Throttle{})
+			limits.ChunksPerSecond = parseRate(name, val)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, limits
+}
+
+// jobOption takes --job=<job_id> out of the arguments.
+func jobOption(args []string) (rest []string, jobID string) {
+	for _, arg := range args {
+		if id, ok := strings.CutPrefix(arg, "--job="); ok {
+			jobID = id
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, jobID
+}
+
+// parseRate reads a per second limit like 500, 64K or 10M (binary units), 0 means none.
+func parseRate(name, val string) int64 {
+	digits, mult := strings.ToUpper(val), int64(1)
+	for i, unit := range []string{"K", "M", "G"} {
+		if d, ok := strings.CutSuffix(digits, unit); ok {
+			digits, mult = d, 1<<(10*(i+1))
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		fmt.Printf("%s must be a number like 500, 64K or 10M, got %q\n", name, val)
+		os.Exit(1)
+	}
+	return n * mult
+}
+
+func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string, weight int, plan string, limits *proto.Throttle) {
+	timeout := 10 * time.Second
+	if plan != "" {
+		timeout = time.Minute // lists every node
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	response, err := client.AddNode(ctx, &proto.AddNodeRequest{
 		NodeAddress: nodeAddr,
+		Weight:      int32(weight),
+		PlanOnly:    plan != "",
+		Throttle:    limits,
 	})
 	if err != nil {
 		log.Fatalf("AddNode RPC failed: %v", err)
 	}
+	if plan != "" {
+		printPlan(response.Plan, plan, "adding "+nodeAddr)
+		return
+	}
 
 	fmt.Printf("Successfully added node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	fmt.Printf("Migrating chunks in job %s\n", response.JobId)
+	waitJob(client, response.JobId, "done")
 }
 
-func removeNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func removeNode(client proto.VideoContentAdminServiceClient, nodeAddr string, plan string, limits *proto.Throttle) {
+	timeout := 10 * time.Second
+	if plan != "" {
+		timeout = time.Minute // lists every node
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	response, err := client.RemoveNode(ctx, &proto.RemoveNodeRequest{
 		NodeAddress: nodeAddr,
+		PlanOnly:    plan != "",
+		Throttle:    limits,
 	})
 	if err != nil {
 		log.Fatalf("RemoveNode RPC failed: %v", err)
 	}
+	if plan != "" {
+		printPlan(response.Plan, plan, "removing "+nodeAddr)
+		return
+	}
 
 	fmt.Printf("Successfully removed node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	if response.JobId == "" {
+		fmt.Println("Node was drained, nothing left to migrate")
+		return
+	}
+	fmt.Printf("Migrating chunks in job %s\n", response.JobId)
+	waitJob(client, response.JobId, "done")
 }
 
 func listNodes(client proto.VideoContentAdminServiceClient) {
@@ -103,9 +277,281 @@ func listNodes(client proto.VideoContentAdminServiceClient) {
 	fmt.Println("Storage cluster nodes:")
 	if len(response.Nodes) == 0 {
 		fmt.Println("  No nodes in cluster")
-	} else {
+	} else if len(response.NodeInfo) == 0 {
+		// older server without placement details
 		for _, node := range response.Nodes {
 			fmt.Printf("  - %s\n", node)
 		}
+	} else {
+		for _, node := range response.NodeInfo {
+			lastSeen := "never"
+			if node.LastSeen != nil {
+				lastSeen = node.LastSeen.AsTime().Local().Format(time.DateTime)
+			}
+			drained := ""
+			if node.Drained {
+				drained = "  drained"
+			}
+			fmt.Printf("  - %s  weight %d  %.1f%% of keyspace  %s (last seen %s)%s\n",
+				node.Address, node.Weight, node.KeyspaceShare*100, node.Health, lastSeen, drained)
+		}
+	}
+}
+
+func clusterStats(client proto.VideoContentAdminServiceClient, videos bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) // every node walks its files
+	defer cancel()
+
+	response, err := client.ClusterStats(ctx, &proto.ClusterStatsRequest{})
+	if err != nil {
+		log.Fatalf("ClusterStats RPC failed: %v", err)
+	}
+
+	fmt.Printf("Storage cluster: %d files, %s on %d nodes\n",
+		response.FileCount, formatBytes(response.TotalBytes), len(response.Nodes))
+	for _, node := range response.Nodes {
+		drained := ""
+		if node.Drained {
+			drained = "  drained"
+		}
+		if node.Error != "" {
+			fmt.Printf("  - %s  weight %d  %.1f%% of keyspace  unreachable%s\n",
+				node.Address, node.Weight, node.KeyspaceShare*100, drained)
+			fmt.Printf("    error: %s\n", node.Error)
+			continue
+		}
+		disk := "disk unknown"
+		if node.DiskBytes >= 0 {
+			disk = fmt.Sprintf("%s free of %s", formatBytes(node.FreeBytes), formatBytes(node.DiskBytes))
+		}
+		fmt.Printf("  - %s  weight %d  %.1f%% of keyspace  %.1f%% of bytes  %d files, %s  %s%s\n",
+			node.Address, node.Weight, node.KeyspaceShare*100, node.ByteShare*100,
+			node.FileCount, formatBytes(node.TotalBytes), disk, drained)
+		if videos {
+			for _, v := range node.Videos {
+				fmt.Printf("      %s: %d files, %s\n", v.VideoId, v.FileCount, formatBytes(v.TotalBytes))
+			}
+		}
+	}
+}
+
+// formatBytes prints n in the largest binary unit that keeps it at least 1.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// waitJob polls the job until it ends, printing its progress, and exits with an error unless
+// it ends in the wanted state. Interrupting only stops the polling, the job keeps running on the server.
+func waitJob(client proto.VideoContentAdminServiceClient, jobID string, want string) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		response, err := client.GetJob(ctx, &proto.GetJobRequest{JobId: jobID})
+		cancel()
+		if err != nil {
+			log.Fatalf("GetJob RPC failed: %v", err)
+		}
+		job := response.Job
+		printJob(job)
+		if job.State != "running" && job.State != "rolling_back" {
+			for _, ce := range job.ChunkErrors {
+				fmt.Printf("    chunk %s: %s\n", ce.Key, ce.Error)
+			}
+			if job.Kind == "rebalance" {
+				printAudit(job.Duplicates, job.Orphans, job.OrphanKeys)
+			}
+			fmt.Printf("Number of files migrated: %d\n", job.ChunksMoved)
+			if job.State != want {
+				os.Exit(1)
+			}
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func listJobs(client proto.VideoContentAdminServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response, err := client.ListJobs(ctx, &proto.ListJobsRequest{})
+	if err != nil {
+		log.Fatalf("ListJobs RPC failed: %v", err)
+	}
+	if t := response.GlobalThrottle; t != nil && (t.BytesPerSecond > 0 || t.ChunksPerSecond > 0) {
+		fmt.Printf("Global limits: %s\n", formatThrottle(t))
+	}
+	if len(response.Jobs) == 0 {
+		fmt.Println("No migration jobs")
+	}
+	for _, job := range response.Jobs {
+		printJob(job)
+	}
+}
+
+func cancelJob(client proto.VideoContentAdminServiceClient, jobID string, rollback bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response, err := client.CancelJob(ctx, &proto.CancelJobRequest{JobId: jobID, Rollback: rollback})
+	if err != nil {
+		log.Fatalf("CancelJob RPC failed: %v", err)
+	}
+	if !rollback {
+		fmt.Printf("Canceling job %s, it stops after the chunk in flight\n", jobID)
+		printJob(response.Job)
+		return
+	}
+	fmt.Printf("Rolling back job %s\n", jobID)
+	waitJob(client, jobID, "rolled_back")
+}
+
+func resumeJob(client proto.VideoContentAdminServiceClient, jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response, err := client.ResumeJob(ctx, &proto.ResumeJobRequest{JobId: jobID})
+	if err != nil {
+		log.Fatalf("ResumeJob RPC failed: %v", err)
+	}
+	fmt.Printf("Resumed job %s\n", jobID)
+	if response.Job.Rollback {
+		waitJob(client, jobID, "rolled_back") // it was rolling back, it carries on with that
+		return
+	}
+	waitJob(client, jobID, "done")
+}
+
+func printJob(job *proto.Job) {
+	fmt.Printf("  %s  %s %s  %s  %d scanned, %d moved, %d failed, %d bytes  started %s\n",
+		job.Id, job.Kind, job.NodeAddress, job.State, job.ChunksScanned, job.ChunksMoved,
+		job.ChunksFailed, job.BytesMoved, job.Started.AsTime().Local().Format(time.DateTime))
+	if t := job.Throttle; t != nil && (t.BytesPerSecond > 0 || t.ChunksPerSecond > 0) {
+		fmt.Printf("    limits: %s\n", formatThrottle(t))
+	}
+	if job.Error != "" {
+		fmt.Printf("    error: %s\n", job.Error)
+	}
+}
+
+func setThrottle(client proto.VideoContentAdminServiceClient, jobID string, limits *proto.Throttle) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response, err := client.SetThrottle(ctx, &proto.SetThrottleRequest{JobId: jobID, Throttle: limits})
+	if err != nil {
+		log.Fatalf("SetThrottle RPC failed: %v", err)
+	}
+	if jobID == "" {
+		fmt.Printf("Global limits: %s\n", formatThrottle(response.GlobalThrottle))
+		return
+	}
+	printJob(response.Job)
+}
+
+// formatThrottle prints the limits, "none" for an unlimited one.
+func formatThrottle(t *proto.Throttle) string {
+	bytes, chunks := "none", "none"
+	if t.BytesPerSecond > 0 {
+		bytes = formatBytes(t.BytesPerSecond) + "/s"
+	}
+	if t.ChunksPerSecond > 0 {
+		chunks = fmt.Sprintf("%d chunks/s", t.ChunksPerSecond)
+	}
+	return fmt.Sprintf("bytes %s, chunks %s", bytes, chunks)
+}
+
+func drainNode(client proto.VideoContentAdminServiceClient, nodeAddr string, undrain bool, limits *proto.Throttle) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	response, err := client.DrainNode(ctx, &proto.DrainNodeRequest{NodeAddress: nodeAddr, Undrain: undrain, Throttle: limits})
+	if err != nil {
+		log.Fatalf("DrainNode RPC failed: %v", err)
+	}
+	if undrain {
+		fmt.Printf("Node %s takes writes again\n", nodeAddr)
+		return
+	}
+	fmt.Printf("Node %s is drained, copying its chunks off in job %s\n", nodeAddr, response.JobId)
+	waitJob(client, response.JobId, "done")
+}
+
+func rebalance(client proto.VideoContentAdminServiceClient, plan string, limits *proto.Throttle) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute) // the plan lists every node
+	defer cancel()
+
+	response, err := client.Rebalance(ctx, &proto.RebalanceRequest{PlanOnly: plan != "", Throttle: limits})
+	if err != nil {
+		log.Fatalf("Rebalance RPC failed: %v", err)
+	}
+	if plan != "" {
+		printPlan(response.Plan, plan, "rebalancing")
+		if plan != "json" {
+			printAudit(response.Duplicates, response.Orphans, response.OrphanKeys)
+		}
+		return
+	}
+	fmt.Printf("Rebalancing in job %s\n", response.JobId)
+	waitJob(client, response.JobId, "done")
+}
+
+// printAudit shows the duplicated and orphaned chunks a rebalance found.
+func printAudit(duplicates, orphans int64, orphanKeys []string) {
+	fmt.Printf("Chunks with more copies than replicas: %d\n", duplicates)
+	fmt.Printf("Chunks of videos missing from the metadata: %d\n", orphans)
+	for _, key := range orphanKeys {
+		fmt.Printf("    %s\n", key)
+	}
+	if int64(len(orphanKeys)) < orphans {
+		fmt.Printf("    and %d more\n", orphans-int64(len(orphanKeys)))
+	}
+}
+
+// printPlan shows a migration plan either as JSON or as per node totals.
+func printPlan(plan *proto.MigrationPlan, format, what string) {
+	if format == "json" {
+		out, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(plan)
+		if err != nil {
+			log.Fatalf("Failed to encode plan: %v", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	type nodeTotals struct {
+		sent, sentBytes, received, receivedBytes, dropped int64
+	}
+	totals := make(map[string]*nodeTotals)
+	node := func(addr string) *nodeTotals {
+		if totals[addr] == nil {
+			totals[addr] = &nodeTotals{}
+		}
+		return totals[addr]
+	}
+	for _, m := range plan.Moves {
+		node(m.Source).sent++
+		node(m.Source).sentBytes += m.Bytes
+		node(m.Destination).received++
+		node(m.Destination).receivedBytes += m.Bytes
+	}
+	for _, d := range plan.Deletions {
+		node(d.Node).dropped++
+	}
+
+	fmt.Printf("Plan for %s (nothing has been changed):\n", what)
+	fmt.Printf("  %d chunk copies, %d bytes, %d copies deleted\n", len(plan.Moves), plan.TotalBytes, len(plan.Deletions))
+	for _, addr := range slices.Sorted(maps.Keys(totals)) {
+		t := totals[addr]
+		fmt.Printf("  - %s  sends %d (%d bytes), receives %d (%d bytes), deletes %d\n",
+			addr, t.sent, t.sentBytes, t.received, t.receivedBytes, t.dropped)
 	}
 }
@@ -5,16 +5,29 @@ package main
 //This is for running these gRPC servers stubs on the server side
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net"
+	"os"
+	"slices"
 	"strings"
+	"time"
 	storagepb "tritontube/internal/proto/storage"
 	"tritontube/internal/web"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
@@ -24,11 +37,16 @@ type server struct {
 
 //gRPC - remote procedure call bodies
 
+// decomposeKey splits the key and checks both halves are safe to put under baseDir,
+// the error is an InvalidArgument status ready to return
 func decomposeKey(key string) (videoId, filename string, err error) {
 	// Assuming the key is structured as "videoId/filename"
 	parts := strings.SplitN(key, "/", 2) // split into at most 2 pieces
 	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid key format %q, expected \"videoID/filename\"", key)
+		return "", "", status.Errorf(codes.InvalidArgument, "invalid key format %q, expected \"videoID/filename\"", key)
+	}
+	if err := web.ValidateKey(parts[0], parts[1]); err != nil {
+		return "", "", status.Errorf(codes.InvalidArgument, "invalid key %q: %v", key, err)
 	}
 	return parts[0], parts[1], nil
 }
@@ -45,12 +63,12 @@ func (s *server) UploadFile(ctx context.Context, req *storagepb.UploadRequest) (
 	if err != nil {
 		log.Printf("Error decomposing key %s: %v", key, err)
 		return &storagepb.UploadResponse{
-			Success: false}, fmt.Errorf("invalid key format %q, expected \"videoID/filename\": %w", key, err)
+			Success: false}, err
 	}
 
-	if err := s.fs.Write(videoId, filename, data); err != nil {
+	if err := s.fs.WriteFrom(videoId, filename, bytes.NewReader(data), req.GetSha256()); err != nil {
 		return &storagepb.UploadResponse{
-			Success: false}, err
+			Success: false}, storageError(key, err)
 	}
 	return &storagepb.UploadResponse{Success: true}, nil
 }
@@ -65,9 +83,12 @@ func (s *server) DownloadFile(ctx context.Context, req *storagepb.DownloadReques
 		log.Printf("Error decomposing key %s: %v", key, err)
 		return &storagepb.DownloadResponse{
 			Found: false,
-			Data:  nil}, fmt.Errorf("invalid key format %q, expected \"videoID/filename\": %w", key, err)
+			Data:  nil}, err
 	}
 	data, err := s.fs.Read(videoId, filename)
+	if errors.Is(err, web.ErrCorrupt) || errors.Is(err, web.ErrInvalidPath) {
+		return nil, storageError(key, err)
+	}
 	if err != nil {
 		return &storagepb.DownloadResponse{
 			Found: false,
@@ -88,30 +109,220 @@ func (s *server) DeleteFile(ctx context.Context, req *storagepb.DeleteRequest) (
 	if err != nil {
 		log.Printf("Error decomposing key %s: %v", key, err)
 		return &storagepb.DeleteResponse{
-			Success: false}, fmt.Errorf("invalid key format %q, expected \"videoID/filename\": %w", key, err)
+			Success: false}, err
 	}
 	// log.Printf("Received file delete request for file: %s", key)
 	if err := s.fs.Delete(videoId, filename); err != nil {
 		return &storagepb.DeleteResponse{
-			Success: false}, err
+			Success: false}, storageError(key, err)
 	}
 	return &storagepb.DeleteResponse{Success: true}, nil
 }
 
 func (s *server) ListFiles(ctx context.Context, req *storagepb.ListFilesRequest) (*storagepb.ListFilesResponse, error) {
-	keys, err := s.fs.ListAll()
+	keys, sizes, err := s.fs.ListAllSizes()
 	if err != nil {
 		log.Printf("Error listing files: %v", err)
 		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
 	return &storagepb.ListFilesResponse{
-		Keys: keys,
+		Keys:  keys,
+		Sizes: sizes,
 	}, nil
 }
 
+// GetStats counts the files by video and reports the disk space left under baseDir
+func (s *server) GetStats(ctx context.Context, req *storagepb.GetStatsRequest) (*storagepb.GetStatsResponse, error) {
+	keys, sizes, err := s.fs.ListAllSizes()
+	if err != nil {
+		log.Printf("Error listing files: %v", err)
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	resp := &storagepb.GetStatsResponse{FileCount: int64(len(keys)), FreeBytes: -1, DiskBytes: -1}
+	videos := make(map[string]*storagepb.VideoStats)
+	for i, key := range keys {
+		videoId, _, _ := strings.Cut(key, "/")
+		v := videos[videoId]
+		if v == nil {
+			v = &storagepb.VideoStats{VideoId: videoId}
+			videos[videoId] = v
+		}
+		v.FileCount++
+		v.TotalBytes += sizes[i]
+		resp.TotalBytes += sizes[i]
+	}
+	for _, videoId := range slices.Sorted(maps.Keys(videos)) {
+		resp.Videos = append(resp.Videos, videos[videoId])
+	}
+
+	free, total, err := s.fs.DiskSpace()
+	if err != nil {
+		log.Printf("Error reading disk space: %v", err) // still worth sending the counts
+	} else {
+		resp.FreeBytes, resp.DiskBytes = free, total
+	}
+	return resp, nil
+}
+
+// uploadStreamReader turns the frames of an UploadFileStream into an io.Reader.
+type uploadStreamReader struct {
+	stream storagepb.StorageService_UploadFileStreamServer
+	buf    []byte // rest of the current frame
+}
+
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err // io.EOF once the client is done sending
+		}
+		r.buf = msg.GetData()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// UploadFileStream writes the frames straight to disk as they arrive
+func (s *server) UploadFileStream(stream storagepb.StorageService_UploadFileStreamServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "upload stream closed before the first frame: %v", err)
+	}
+	key := first.GetKey()
+	videoId, filename, err := decomposeKey(key)
+	if err != nil {
+		log.Printf("Error decomposing key %s: %v", key, err)
+		return err
+	}
+
+	r := &uploadStreamReader{stream: stream, buf: first.GetData()}
+	if err := s.fs.WriteFrom(videoId, filename, r, first.GetSha256()); err != nil {
+		return storageError(key, err)
+	}
+	return stream.SendAndClose(&storagepb.UploadResponse{Success: true})
+}
+
+// storageError gives corrupt content and bad paths their own gRPC codes, so clients can tell them from a failed disk
+func storageError(key string, err error) error {
+	switch {
+	case errors.Is(err, web.ErrCorrupt):
+		log.Printf("Corrupt file %s: %v", key, err)
+		return status.Errorf(codes.DataLoss, "%s: %v", key, err)
+	case errors.Is(err, web.ErrInvalidPath):
+		log.Printf("Rejected key %q: %v", key, err)
+		return status.Errorf(codes.InvalidArgument, "invalid key %q: %v", key, err)
+	case errors.Is(err, web.ErrFull):
+		log.Printf("Rejected upload of %s: %v", key, err)
+		return status.Errorf(codes.ResourceExhausted, "%s: %v", key, err)
+	}
+	return err
+}
+
+// DownloadFileStream sends the file back in frames of web.StreamFrameSize
+func (s *server) DownloadFileStream(req *storagepb.DownloadRequest, stream storagepb.StorageService_DownloadFileStreamServer) error {
+	key := req.Key
+	videoId, filename, err := decomposeKey(key)
+	if err != nil {
+		log.Printf("Error decomposing key %s: %v", key, err)
+		return err
+	}
+	// the open file keeps its content even if a write replaces it while it streams,
+	// the checksum comes with it so the two match
+	f, sum, err := s.fs.OpenChecked(videoId, filename)
+	switch {
+	case errors.Is(err, web.ErrInvalidPath):
+		return storageError(key, err)
+	case errors.Is(err, os.ErrNotExist):
+		return status.Errorf(codes.NotFound, "file not found %s/%s", videoId, filename)
+	case err != nil:
+		return status.Errorf(codes.Internal, "failed to open %s: %v", key, err)
+	}
+	defer f.Close()
+
+	// hash as it goes out, a mismatch fails the stream after the last frame
+	// and the client throws away what it got
+	h := sha256.New()
+	buf := make([]byte, web.StreamFrameSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if err := stream.Send(&storagepb.DownloadChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			if got := h.Sum(nil); sum != nil && !bytes.Equal(got, sum) {
+				s.fs.MarkCorrupt(videoId, filename, sum) // ReadRange refuses it from now on
+				return storageError(key, fmt.Errorf("%w: got %x, want %x", web.ErrCorrupt, got, sum))
+			}
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+		}
+	}
+}
+
+// ReadRange seeks to the slice asked for and sends only that, capped at web.MaxRangeLength
+func (s *server) ReadRange(ctx context.Context, req *storagepb.ReadRangeRequest) (*storagepb.ReadRangeResponse, error) {
+	key := req.Key
+	videoId, filename, err := decomposeKey(key)
+	if err != nil {
+		log.Printf("Error decomposing key %s: %v", key, err)
+		return nil, err
+	}
+	if req.Length < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "negative length %d", req.Length)
+	}
+	length := req.Length
+	if length == 0 || length > web.MaxRangeLength {
+		length = web.MaxRangeLength
+	}
+	data, size, err := s.fs.ReadRange(videoId, filename, req.Offset, length)
+	switch {
+	case errors.Is(err, web.ErrInvalidPath), errors.Is(err, web.ErrCorrupt):
+		return nil, storageError(key, err)
+	case errors.Is(err, os.ErrNotExist):
+		return nil, status.Errorf(codes.NotFound, "file not found %s/%s", videoId, filename)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	return &storagepb.ReadRangeResponse{Data: data, TotalSize: size}, nil
+}
+
+// ChecksumFile hashes the file where it lies, migrations check a copy with it before deleting the source
+func (s *server) ChecksumFile(ctx context.Context, req *storagepb.ChecksumRequest) (*storagepb.ChecksumResponse, error) {
+	key := req.Key
+	videoId, filename, err := decomposeKey(key)
+	if err != nil {
+		log.Printf("Error decomposing key %s: %v", key, err)
+		return nil, err
+	}
+	f, err := s.fs.Open(videoId, filename)
+	if errors.Is(err, web.ErrInvalidPath) {
+		return nil, storageError(key, err)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "file not found %s/%s", videoId, filename)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read %s: %v", key, err)
+	}
+	return &storagepb.ChecksumResponse{Sha256: h.Sum(nil), Size: n}, nil
+}
+
 func main() {
 	host := flag.String("host", "localhost", "Host address for the server")
 	port := flag.Int("port", 8090, "Port number for the server")
+	durability := flag.String("durability", "file", "How writes are flushed: none, file (fsync the file) or full (also fsync the directory)")
+	quota := flag.Int64("quota", 0, "Most bytes of files to store, 0 for no limit")
+	reserve := flag.Int64("reserve", 0, "Free disk space in bytes uploads must leave on the filesystem holding baseDir")
 	flag.Parse()
 
 	// Validate arguments
@@ -125,6 +336,16 @@ func main() {
 		return
 	}
 	baseDir := flag.Arg(0)
+	level, err := web.ParseDurability(*durability)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if *quota < 0 || *reserve < 0 {
+		fmt.Println("Error: -quota and -reserve can't be negative")
+		return
+	}
+	fsOpts := web.FSOptions{Durability: level, Quota: *quota, Reserve: *reserve}
 
 	fmt.Println("Starting storage server...")
 	fmt.Printf("Host: %s\n", *host)
@@ -140,9 +361,15 @@ func main() {
 	}
 
 	// new gRPC server
-	grpcServer := grpc.NewServer()
-	fs_svc := web.NewFSVideoContentService(baseDir)
+	// the web servers ping idle connections every 30s by default, let them
+	grpcServer := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             10 * time.Second,
+		PermitWithoutStream: true,
+	}))
+	fs_svc := web.NewFSVideoContentServiceWith(baseDir, fsOpts)
 	storagepb.RegisterStorageServiceServer(grpcServer, &server{fs: fs_svc})
+	// standard gRPC health service, the web servers probe it to route around dead nodes
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
 	log.Printf("Storage server listening on %s; storing files under %s", addr, baseDir)
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve gRPC server: %v", err)
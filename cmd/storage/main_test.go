@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	storagepb "tritontube/internal/proto/storage"
+	"tritontube/internal/web"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves a storage server over baseDir in memory and returns a client for it.
+func startServer(t *testing.T, baseDir string) storagepb.StorageServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	storagepb.RegisterStorageServiceServer(srv, &server{fs: web.NewFSVideoContentService(baseDir)})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return storagepb.NewStorageServiceClient(conn)
+}
+
+// snapshot records every path under dir with its mode, size, time and content.
+func snapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := info.Mode().String() + " " + info.ModTime().String()
+		if info.Mode().IsRegular() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			entry += " " + string(data)
+		}
+		files[path] = entry
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// TestRejectsKeysOutsideRoot sends keys that try to reach outside baseDir, or clash with the
+// server's own files, to every RPC taking a key. All of them have to be InvalidArgument
+// and nothing outside baseDir may change.
+func TestRejectsKeysOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	baseDir := filepath.Join(dir, "base")
+	outside := filepath.Join(dir, "outside")
+	if err := os.MkdirAll(filepath.Join(baseDir, "v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(baseDir, "escape")); err != nil {
+		t.Skipf("can't create symlinks here: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(baseDir, "v1", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	client := startServer(t, baseDir)
+	if _, err := client.UploadFile(context.Background(), &storagepb.UploadRequest{Key: "v1/chunk.m4s", Data: []byte("chunk")}); err != nil {
+		t.Fatalf("upload of a good key failed: %v", err)
+	}
+	before := snapshot(t, outside)
+
+	keys := []string{
+		"../outside/secret",
+		"v1/../../outside/secret",
+		"../../etc/x",
+		"/abs",
+		"v1/a/./b",
+		"v1/a//b",
+		"v1/a\x00b",
+		`v1\..\..\outside\secret`,
+		`v1/..\..\outside\secret`,
+		"v1/chunk.m4s.sha256",
+		"v1/.chunk.m4s.tmp-123",
+		"escape/secret",
+		"escape/new",
+		"v1/secret",
+		"nokey",
+	}
+	rpcs := map[string]func(ctx context.Context, key string) error{
+		"UploadFile": func(ctx context.Context, key string) error {
+			_, err := client.UploadFile(ctx, &storagepb.UploadRequest{Key: key, Data: []byte("pwned")})
+			return err
+		},
+		"UploadFileStream": func(ctx context.Context, key string) error {
+			stream, err := client.UploadFileStream(ctx)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(&storagepb.UploadChunk{Key: key, Data: []byte("pwned")}); err != nil && err != io.EOF {
+				return err
+			}
+			_, err = stream.CloseAndRecv()
+			return err
+		},
+		"DownloadFile": func(ctx context.Context, key string) error {
+			_, err := client.DownloadFile(ctx, &storagepb.DownloadRequest{Key: key})
+			return err
+		},
+		"DownloadFileStream": func(ctx context.Context, key string) error {
+			stream, err := client.DownloadFileStream(ctx, &storagepb.DownloadRequest{Key: key})
+			if err != nil {
+				return err
+			}
+			for {
+				if _, err := stream.Recv(); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+			}
+		},
+		"DeleteFile": func(ctx context.Context, key string) error {
+			_, err := client.DeleteFile(ctx, &storagepb.DeleteRequest{Key: key})
+			return err
+		},
+		"ChecksumFile": func(ctx context.Context, key string) error {
+			_, err := client.ChecksumFile(ctx, &storagepb.ChecksumRequest{Key: key})
+			return err
+		},
+		"ReadRange": func(ctx context.Context, key string) error {
+			_, err := client.ReadRange(ctx, &storagepb.ReadRangeRequest{Key: key, Length: 4})
+			return err
+		},
+	}
+	for _, name := range slices.Sorted(maps.Keys(rpcs)) {
+		for _, key := range keys {
+			t.Run(name+"/"+key, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := rpcs[name](ctx, key); status.Code(err) != codes.InvalidArgument {
+					t.Errorf("%s(%q) = %v, want InvalidArgument", name, key, err)
+				}
+			})
+		}
+	}
+
+	stats, err := client.GetStats(context.Background(), &storagepb.GetStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.FileCount != 1 || stats.TotalBytes != int64(len("chunk")) {
+		t.Errorf("GetStats counted %d files of %d bytes, want only v1/chunk.m4s", stats.FileCount, stats.TotalBytes)
+	}
+	for _, v := range stats.Videos {
+		if v.VideoId != "v1" {
+			t.Errorf("GetStats lists video %q, want only v1", v.VideoId)
+		}
+	}
+
+	after := snapshot(t, outside)
+	for path, entry := range before {
+		if after[path] != entry {
+			t.Errorf("%s changed: was %q, now %q", path, entry, after[path])
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			t.Errorf("%s was created outside the storage root", path)
+		}
+	}
+}
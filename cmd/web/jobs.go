@@ -0,0 +1,472 @@
+package main
+
+// background migration jobs started by AddNode, RemoveNode, Rebalance and DrainNode
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	adminpb "tritontube/internal/proto"
+	"tritontube/internal/web"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// job states as reported by GetJob
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+
+	jobRollingBack = "rolling_back" // undoing the membership change after an abort
+	jobRolledBack  = "rolled_back"
+)
+
+// maxFinishedJobs is how many finished jobs are kept around for ListJobs.
+const maxFinishedJobs = 100
+
+// maxChunkErrors is how many per chunk errors a job keeps, the failed count goes on past it.
+const maxChunkErrors = 100
+
+// migrationJob moves chunks after a membership change. The counters are updated
+// by the migration while it runs, everything else is guarded by mu.
+type migrationJob struct {
+	id   string
+	kind string // "add", "remove", "rebalance" or "drain"
+	node string // node being added or removed
+
+	scanned    atomic.Int64
+	moved      atomic.Int64
+	failed     atomic.Int64
+	full       atomic.Int64 // the failed chunks a target node had no room for
+	bytesMoved atomic.Int64
+
+	audit    chunkAudit // set by a rebalance once it has listed the nodes, guarded by mu
+	throttle *throttle  // the job's own limits, see SetThrottle
+
+	mu          sync.Mutex
+	rec         web.MigrationRecord
+	cancel      context.CancelFunc // stops the phase that is running
+	abort       bool               // roll back once the forward migration stops
+	state       string
+	err         error
+	chunkErrors []*adminpb.ChunkError
+	started     time.Time
+	finished    time.Time
+}
+
+func newJobID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (j *migrationJob) running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state == jobRunning || j.state == jobRollingBack
+}
+
+// undoable tells whether the job changes the membership in a way a rollback can undo.
+func (j *migrationJob) undoable() bool {
+	return j.kind == "add" || j.kind == "remove"
+}
+
+func (j *migrationJob) record() web.MigrationRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.rec
+}
+
+// stop cancels the phase that is running, with abort the job then rolls back.
+func (j *migrationJob) stop(abort bool) {
+	j.mu.Lock()
+	j.abort = j.abort || abort
+	cancel := j.cancel
+	j.mu.Unlock()
+	cancel()
+}
+
+// needsRollback tells whether the forward migration was aborted and has to be undone. A job that
+// failed or left chunks behind keeps its change like a plain cancel does, so a transient error
+// doesn't move every chunk back; it can be resumed, or rolled back with cancel --rollback.
+func (j *migrationJob) needsRollback() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.abort && !j.rec.Rollback && j.undoable()
+}
+
+// startRollback switches an aborted job to undoing its membership change. The counters start
+// over for the moves back, the error says why the job is rolling back.
+func (j *migrationJob) startRollback(cancel context.CancelFunc) web.MigrationRecord {
+	j.scanned.Store(0)
+	j.moved.Store(0)
+	j.failed.Store(0)
+	j.full.Store(0)
+	j.bytesMoved.Store(0)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.rec.Rollback = true
+	j.state = jobRollingBack
+	j.err = errors.New("aborted")
+	j.cancel = cancel
+	j.abort = false
+	return j.rec
+}
+
+// chunkFailed records a chunk that couldn't be moved, the migration carries on with the others.
+func (j *migrationJob) chunkFailed(key string, err error) {
+	j.failed.Add(1)
+	if errors.Is(err, web.ErrNodeFull) {
+		j.full.Add(1)
+	}
+	log.Printf("Migration job %s: chunk %s: %v", j.id, key, err)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.chunkErrors) < maxChunkErrors {
+		j.chunkErrors = append(j.chunkErrors, &adminpb.ChunkError{Key: key, Error: err.Error()})
+	}
+}
+
+// finish records how the migration ended, a canceled context counts as canceled rather than failed.
+// A migration that got through but left chunks behind is failed too.
+func (j *migrationJob) finish(err error) {
+	if n := j.failed.Load(); err == nil && n > 0 {
+		err = j.failedErr(n)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now()
+	switch {
+	case err == nil && j.rec.Rollback:
+		j.state = jobRolledBack
+	case err == nil:
+		j.state = jobDone
+	case errors.Is(err, context.Canceled):
+		j.state = jobCanceled
+	case j.rec.Rollback && j.err != nil:
+		j.state = jobFailed
+		j.err = fmt.Errorf("rollback failed: %w (rolling back because: %v)", err, j.err)
+	default:
+		j.state = jobFailed
+		j.err = err
+	}
+}
+
+// failedErr says how many chunks were left behind, and how many of them because a node was full,
+// since those need space freed or a quota raised rather than a rerun.
+func (j *migrationJob) failedErr(n int64) error {
+	if full := j.full.Load(); full > 0 {
+		return fmt.Errorf("%d chunks couldn't be moved, %d of them because the target node was full: %w", n, full, web.ErrNodeFull)
+	}
+	return fmt.Errorf("%d chunks couldn't be moved", n)
+}
+
+func (j *migrationJob) proto() *adminpb.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pb := &adminpb.Job{
+		Id:            j.id,
+		Kind:          j.kind,
+		NodeAddress:   j.node,
+		State:         j.state,
+		ChunksScanned: j.scanned.Load(),
+		ChunksMoved:   j.moved.Load(),
+		ChunksFailed:  j.failed.Load(),
+		BytesMoved:    j.bytesMoved.Load(),
+		ChunkErrors:   j.chunkErrors,
+		Started:       timestamppb.New(j.started),
+		Rollback:      j.rec.Rollback,
+		Duplicates:    j.audit.duplicates,
+		Orphans:       j.audit.orphans,
+		OrphanKeys:    j.audit.orphanKeys,
+	}
+	if j.throttle != nil {
+		pb.Throttle = j.throttle.proto()
+	}
+	if j.err != nil {
+		pb.Error = j.err.Error()
+	}
+	if !j.finished.IsZero() {
+		pb.Finished = timestamppb.New(j.finished)
+	}
+	return pb
+}
+
+// checkIdle fails if a migration is still running, two at once would fight over the same chunks.
+// Caller holds a.mu.
+func (a *AdminServer) checkIdle() error {
+	if a.active != nil && a.active.running() {
+		return status.Errorf(codes.FailedPrecondition, "migration job %s (%s %s) is still running", a.active.id, a.active.kind, a.active.node)
+	}
+	return nil
+}
+
+// newRecord journals a migration before its membership change is made, so a crash
+// right after the change still leaves a record to resume from. Caller holds a.mu.
+func (a *AdminServer) newRecord(kind string, member web.Member, limits *adminpb.Throttle) (web.MigrationRecord, error) {
+	bytesPerSec, chunksPerSec, err := checkThrottle(limits)
+	if err != nil {
+		return web.MigrationRecord{}, err
+	}
+	rec := web.MigrationRecord{
+		ID:           newJobID(),
+		Kind:         kind,
+		Member:       member,
+		Before:       a.svc.Members(),
+		Owner:        a.addr,
+		State:        jobRunning,
+		Started:      time.Now(),
+		BytesPerSec:  bytesPerSec,
+		ChunksPerSec: chunksPerSec,
+	}
+	return rec, a.saveRecord(rec)
+}
+
+func (a *AdminServer) saveRecord(rec web.MigrationRecord) error {
+	if a.journal == nil {
+		return nil
+	}
+	if err := a.journal.SaveMigration(rec); err != nil {
+		return status.Errorf(codes.Unavailable, "failed to journal migration: %v", err)
+	}
+	return nil
+}
+
+// dropRecord forgets a migration that never got going, or finished.
+func (a *AdminServer) dropRecord(id string) {
+	if a.journal == nil {
+		return
+	}
+	if err := a.journal.DeleteMigration(id); err != nil {
+		log.Printf("Failed to delete migration record %s: %v", id, err)
+	}
+}
+
+// startJob runs the migration for rec in the background and returns its job right away.
+// If it is aborted it rolls back: the job's membership change is undone and the chunks
+// already moved go back to their old owners. Caller holds a.mu.
+func (a *AdminServer) startJob(rec web.MigrationRecord) *migrationJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &migrationJob{
+		id:       rec.ID,
+		kind:     rec.Kind,
+		node:     rec.Member.Address,
+		rec:      rec,
+		throttle: newThrottle(rec.BytesPerSec, rec.ChunksPerSec),
+		cancel:   cancel,
+		state:    jobRunning,
+		started:  time.Now(),
+	}
+	// a resumed job takes the place of its earlier run
+	a.jobs = slices.DeleteFunc(a.jobs, func(j *migrationJob) bool { return j.id == rec.ID })
+	a.jobs = append(a.jobs, job)
+	a.active = job
+	a.pruneJobs()
+
+	go func() {
+		err := stopped(ctx, a.migration(ctx, job))
+		cancel()
+		if job.needsRollback() {
+			ctx, cancel = context.WithCancel(context.Background())
+			rec := job.startRollback(cancel)
+			log.Printf("Migration job %s (%s %s) rolling back: %v", job.id, job.kind, job.node, job.proto().Error)
+			if err := a.saveRecord(rec); err != nil {
+				log.Printf("Migration job %s: %v", job.id, err)
+			}
+			err = stopped(ctx, a.migration(ctx, job))
+			cancel()
+		}
+		job.finish(err)
+		pb := job.proto()
+		log.Printf("Migration job %s (%s %s) %s: %d scanned, %d moved, %d failed, %d bytes %s",
+			job.id, job.kind, job.node, pb.State, pb.ChunksScanned, pb.ChunksMoved, pb.ChunksFailed, pb.BytesMoved, pb.Error)
+		if pb.State == jobDone || pb.State == jobRolledBack {
+			a.dropRecord(job.id)
+			return
+		}
+		// keep it so it can be resumed
+		rec := job.record()
+		rec.State, rec.Error = pb.State, pb.Error
+		if err := a.saveRecord(rec); err != nil {
+			log.Printf("Migration job %s: %v", job.id, err)
+		}
+	}()
+	return job
+}
+
+// stopped turns whatever error a canceled migration ended with, e.g. the Canceled status of
+// the RPC that was in flight, into ctx's error, so it counts as canceled and not as failed.
+func stopped(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// finishedJob lists a journaled migration that isn't running, so it can be looked at and resumed.
+// Caller holds a.mu.
+func (a *AdminServer) finishedJob(rec web.MigrationRecord) {
+	job := &migrationJob{
+		id:       rec.ID,
+		kind:     rec.Kind,
+		node:     rec.Member.Address,
+		rec:      rec,
+		throttle: newThrottle(rec.BytesPerSec, rec.ChunksPerSec),
+		cancel:   func() {},
+		state:    rec.State,
+		started:  rec.Started,
+	}
+	if rec.Error != "" {
+		job.err = errors.New(rec.Error)
+	}
+	a.jobs = append(a.jobs, job)
+}
+
+// resumeJournal picks up the migrations this server left behind. The one that was running when
+// it died starts again, failed and canceled ones wait for ResumeJob.
+func (a *AdminServer) resumeJournal() error {
+	if a.journal == nil {
+		return nil
+	}
+	recs, err := a.journal.LoadMigrations()
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, rec := range recs {
+		if rec.Owner != a.addr {
+			continue // another web server's job
+		}
+		if rec.State != jobRunning {
+			a.finishedJob(rec)
+			continue
+		}
+		if err := a.checkIdle(); err != nil {
+			// only one can run, leave the rest to ResumeJob
+			rec.State, rec.Error = jobFailed, "interrupted by a restart"
+			a.finishedJob(rec)
+			continue
+		}
+		log.Printf("Resuming migration job %s (%s %s)", rec.ID, rec.Kind, rec.Member.Address)
+		a.startJob(rec)
+	}
+	return nil
+}
+
+// pruneJobs drops the oldest finished jobs once there are too many. Caller holds a.mu.
+func (a *AdminServer) pruneJobs() {
+	extra := len(a.jobs) - maxFinishedJobs - 1 // the active one doesn't count
+	kept := a.jobs[:0]
+	for _, job := range a.jobs {
+		if extra > 0 && !job.running() {
+			extra--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	a.jobs = kept
+}
+
+func (a *AdminServer) findJob(id string) (*migrationJob, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, job := range a.jobs {
+		if job.id == id {
+			return job, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no migration job %q", id)
+}
+
+func (a *AdminServer) GetJob(ctx context.Context, req *adminpb.GetJobRequest) (*adminpb.GetJobResponse, error) {
+	job, err := a.findJob(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	return &adminpb.GetJobResponse{Job: job.proto()}, nil
+}
+
+func (a *AdminServer) ListJobs(ctx context.Context, req *adminpb.ListJobsRequest) (*adminpb.ListJobsResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	response := &adminpb.ListJobsResponse{GlobalThrottle: a.throttle.proto()}
+	for _, job := range a.jobs {
+		response.Jobs = append(response.Jobs, job.proto())
+	}
+	return response, nil
+}
+
+// CancelJob stops a running migration after the chunk it is working on.
+// The membership change itself is kept, chunks that weren't moved yet stay where they are
+// until the job is resumed. With rollback the job is aborted instead: its membership change is
+// undone and the chunks already moved go back, this works on failed and canceled jobs too.
+func (a *AdminServer) CancelJob(ctx context.Context, req *adminpb.CancelJobRequest) (*adminpb.CancelJobResponse, error) {
+	job, err := a.findJob(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	if job.running() {
+		if req.Rollback && !job.undoable() {
+			return nil, status.Errorf(codes.FailedPrecondition, "a %s job has no membership change to roll back", job.kind)
+		}
+		job.stop(req.Rollback)
+		return &adminpb.CancelJobResponse{Job: job.proto()}, nil
+	}
+	if !req.Rollback {
+		return nil, status.Errorf(codes.FailedPrecondition, "migration job %s already %s", job.id, job.proto().State)
+	}
+	if !job.undoable() {
+		return nil, status.Errorf(codes.FailedPrecondition, "a %s job has no membership change to roll back", job.kind)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkIdle(); err != nil {
+		return nil, err
+	}
+	if state := job.proto().State; state == jobDone || state == jobRolledBack {
+		return nil, status.Errorf(codes.FailedPrecondition, "migration job %s already %s", job.id, state)
+	}
+	rec := job.record()
+	rec.State, rec.Error, rec.Rollback = jobRunning, "", true
+	if err := a.saveRecord(rec); err != nil {
+		return nil, err
+	}
+	return &adminpb.CancelJobResponse{Job: a.startJob(rec).proto()}, nil
+}
+
+// ResumeJob runs a failed or canceled migration again. Moves that already happened are
+// skipped, it only finishes what's left. A job that was rolling back carries on rolling back.
+func (a *AdminServer) ResumeJob(ctx context.Context, req *adminpb.ResumeJobRequest) (*adminpb.ResumeJobResponse, error) {
+	job, err := a.findJob(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkIdle(); err != nil {
+		return nil, err
+	}
+	if state := job.proto().State; state == jobDone || state == jobRolledBack {
+		return nil, status.Errorf(codes.FailedPrecondition, "migration job %s already %s", job.id, state)
+	}
+	rec := job.record()
+	rec.State, rec.Error = jobRunning, ""
+	if err := a.saveRecord(rec); err != nil {
+		return nil, err
+	}
+	return &adminpb.ResumeJobResponse{Job: a.startJob(rec).proto()}, nil
+}
@@ -12,6 +12,9 @@ import (
 	"tritontube/internal/web"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // printUsage prints the usage information for the application
@@ -32,151 +35,215 @@ func printUsage() {
 
 type AdminServer struct {
 	adminpb.UnimplementedVideoContentAdminServiceServer
-	svc *web.NWVideoContentService
-	mu  sync.Mutex
+	svc      *web.NWVideoContentService
+	metadata web.VideoMetadataService // tells a rebalance which videos still exist
+	mu       sync.Mutex               // serializes membership changes, guards jobs
+
+	opts     migrationOptions
+	sources  *nodeLimiter // per node limit shared by every migration
+	throttle *throttle    // global speed limits, on top of each job's own
+
+	journal web.MigrationJournal // unfinished migrations, nil if the metadata store can't keep them
+	addr    string               // admin address, tells our journal records from other web servers'
+
+	jobs   []*migrationJob // oldest first
+	active *migrationJob   // latest migration, only one runs at a time
 }
 
-func NewAdminServer(svc *web.NWVideoContentService) *AdminServer {
+func NewAdminServer(svc *web.NWVideoContentService, metadata web.VideoMetadataService, journal web.MigrationJournal, addr string, opts migrationOptions) *AdminServer {
 	return &AdminServer{
-		svc: svc,
+		svc:      svc,
+		metadata: metadata,
+		opts:     opts,
+		sources:  newNodeLimiter(opts.perNode),
+		throttle: newThrottle(opts.bytesPerSec, opts.chunksPerSec),
+		journal:  journal,
+		addr:     addr,
 	}
 }
 
 func (a *AdminServer) ListNodes(ctx context.Context, req *adminpb.ListNodesRequest) (*adminpb.ListNodesResponse, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	nodes := a.svc.Ring.List()
+	placement := a.svc.Placement()
+	nodes := placement.List()
+	shares := placement.Shares()
 	response := &adminpb.ListNodesResponse{
 		Nodes: nodes,
 	}
+	for _, addr := range nodes {
+		st := a.svc.NodeStatus(addr)
+		info := &adminpb.NodeInfo{
+			Address:       addr,
+			Weight:        int32(placement.Weight(addr)),
+			KeyspaceShare: shares[addr],
+			Health:        st.Health.String(),
+			Drained:       a.svc.Drained(addr),
+		}
+		if !st.LastSeen.IsZero() {
+			info.LastSeen = timestamppb.New(st.LastSeen)
+		}
+		response.NodeInfo = append(response.NodeInfo, info)
+	}
 	return response, nil
 }
 
 // add it to the ring
-// read from each server in order to get the chunk names
-// start migration process
-// if chunk goes to server it came from, skip it
-// if chunk goes to a different server, read it from the old server and write it to the new server, and delete it from the old server
-// incremnt count
+// then migrate in the background, the caller gets the job id and polls GetJob
 func (a *AdminServer) AddNode(ctx context.Context, req *adminpb.AddNodeRequest) (*adminpb.AddNodeResponse, error) {
+	weight := int(req.Weight)
+	if weight == 0 {
+		weight = 1 // unset weight means an ordinary node
+	}
+	if err := web.ValidateWeight(req.NodeAddress, weight); err != nil {
+		return nil, err
+	}
+	member := web.Member{Address: req.NodeAddress, Weight: weight}
+	if req.PlanOnly {
+		after, err := a.svc.MembersWith(member)
+		if err != nil {
+			return nil, err
+		}
+		rule, err := a.changedMembers(a.svc.Members(), after)
+		if err != nil {
+			return nil, err
+		}
+		plan, err := a.plan(ctx, a.svc.Placement().List(), rule)
+		if err != nil {
+			return nil, err
+		}
+		return &adminpb.AddNodeResponse{Plan: plan}, nil
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	if err := a.checkIdle(); err != nil {
+		return nil, err
+	}
 
-	// get the list of existing nodes in the cluster
-	serverAddrs := a.svc.Ring.List()
-	// Add the new node address to the consistent hash ring
-	err := a.svc.Ring.Add(req.NodeAddress)
+	// journal the migration first, it records the membership as it was before the add
+	rec, err := a.newRecord("add", member, req.Throttle)
 	if err != nil {
+		return nil, err
+	}
+	// Add the new node address to the consistent hash ring, dial it and save the membership
+	err = a.svc.AddMember(member)
+	if err != nil {
+		a.dropRecord(rec.ID)
 		return &adminpb.AddNodeResponse{MigratedFileCount: 0}, err
 	}
-	//add its gRPC stub and dial
-	a.svc.RegisterNode(req.NodeAddress)
 
-	if len(serverAddrs) == 0 {
-		return nil, fmt.Errorf("no existing nodes in the cluster to migrate from")
-	}
+	job := a.startJob(rec)
+	return &adminpb.AddNodeResponse{JobId: job.id}, nil
+}
 
-	migratedFileCount := int32(0)
-	// Start migration process
-	for _, addr := range serverAddrs {
-		chunkNames, err := a.svc.ListChunkNames(addr)
+func (a *AdminServer) RemoveNode(ctx context.Context, req *adminpb.RemoveNodeRequest) (*adminpb.RemoveNodeResponse, error) {
+	if req.PlanOnly {
+		after, err := a.svc.MembersWithout(req.NodeAddress)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list chunk names for node %s: %w", req.NodeAddress, err)
+			return nil, err
 		}
-
-		for _, chunkName := range chunkNames {
-
-			// (With ring updated) check if the chunk still belongs to the current addr
-			chunkAddr, err := a.svc.Ring.GetNodeForKey(chunkName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get node for chunk %s: %w", chunkName, err)
-			}
-			if chunkAddr == addr {
-				// leave chunk on the same server
-				continue
-			}
-			// they are different, so we need to migrate the chunk
-			// Read/Write/Delete the chunk
-			parts := strings.Split(chunkName, "/")
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("invalid chunk name format %q, expected \"videoID/filename\"", chunkName)
-			}
-			videoId := parts[0]
-			filename := parts[1]
-			data, err := a.svc.ReadFromNode(videoId, filename, addr) // read the file from the old node
-			if err != nil {
-				return nil, fmt.Errorf("failed to read chunk %s from node %s: %w", chunkName, addr, err)
-			}
-			if err := a.svc.Write(videoId, filename, data); err != nil {
-				return nil, fmt.Errorf("failed to write chunk %s to new node %s: %w", chunkName, req.NodeAddress, err)
-			}
-			// Now delete the file from the old node
-			if err := a.svc.DeleteFile(videoId, filename, addr); err != nil {
-				return nil, fmt.Errorf("failed to delete chunk %s from node %s: %w", chunkName, addr, err)
-			}
-			log.Printf("Migrated chunk %s from node %s to new node %s", chunkName, addr, req.NodeAddress)
-			// Increment the migrated file count
-			migratedFileCount++
+		rule, err := a.offNode(req.NodeAddress, after)
+		if err != nil {
+			return nil, err
 		}
+		plan, err := a.plan(ctx, a.svc.Placement().List(), rule)
+		if err != nil {
+			return nil, err
+		}
+		return &adminpb.RemoveNodeResponse{Plan: plan}, nil
 	}
 
-	return &adminpb.AddNodeResponse{MigratedFileCount: migratedFileCount}, nil
-}
-
-func (a *AdminServer) RemoveNode(ctx context.Context, req *adminpb.RemoveNodeRequest) (*adminpb.RemoveNodeResponse, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	if err := a.checkIdle(); err != nil {
+		return nil, err
+	}
 
-	// read all names from the videoId filename and data from the server being shut down
-	chunkNames, err := a.svc.ListChunkNames(req.NodeAddress)
+	if a.svc.Drained(req.NodeAddress) {
+		return a.removeDrained(ctx, req.NodeAddress)
+	}
+
+	// journal the migration first, the membership it records still has the node being shut down,
+	// the migration lists every node in it so it knows which replicas already exist elsewhere
+	member := web.Member{Address: req.NodeAddress, Weight: a.svc.Placement().Weight(req.NodeAddress)}
+	rec, err := a.newRecord("remove", member, req.Throttle)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list chunk names for node %s: %w", req.NodeAddress, err)
+		return nil, err
 	}
 	//remove the node from the consistent hash ring
 	//this ring is how video chunks find a storage home
-	err = a.svc.Ring.Remove(req.NodeAddress)
+	//its connection stays open until the migration is done with it
+	err = a.svc.RemoveMember(req.NodeAddress)
 	if err != nil {
+		a.dropRecord(rec.ID)
 		return &adminpb.RemoveNodeResponse{MigratedFileCount: 0}, err
 	}
 
-	// start migration process
-	// read from node being removed, write to new node, delete from node being removed
-	migratedFileCount := int32(0)
-	for _, chunkName := range chunkNames {
-		parts := strings.Split(chunkName, "/")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid chunk name format %q, expected \"videoID/filename\"", chunkNames)
-		}
-		videoId := parts[0]
-		filename := parts[1]
-
-		data, err := a.svc.ReadFromNode(videoId, filename, req.NodeAddress) // read the file from the node being removed
-		if err != nil {
-			return nil, fmt.Errorf("failed to read chunk %s from node %s: %w", chunkName, req.NodeAddress, err)
-		}
-
-		if err := a.svc.Write(videoId, filename, data); err != nil {
-			return nil, fmt.Errorf("failed to write chunk %s to new node: %w", chunkName, err)
-		}
+	job := a.startJob(rec)
+	return &adminpb.RemoveNodeResponse{JobId: job.id}, nil
+}
 
-		//now delete the file from the node being removed
-		if err := a.svc.DeleteFile(videoId, filename, req.NodeAddress); err != nil {
-			return nil, fmt.Errorf("failed to delete chunk %s from node %s: %w", chunkName, req.NodeAddress, err)
+// DrainNode takes the node out of write rotation and copies its chunks off in a job,
+// or with undrain puts it back.
+func (a *AdminServer) DrainNode(ctx context.Context, req *adminpb.DrainNodeRequest) (*adminpb.DrainNodeResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if req.Undrain {
+		// the job would go on copying chunks off a node that takes writes again
+		if j := a.active; j != nil && j.kind == "drain" && j.node == req.NodeAddress && j.running() {
+			return nil, status.Errorf(codes.FailedPrecondition, "drain job %s for %s is still running, cancel it first", j.id, req.NodeAddress)
 		}
-		log.Printf("Migrated chunk %s from node %s to new node", chunkName, req.NodeAddress)
-		migratedFileCount++
+		return &adminpb.DrainNodeResponse{}, a.svc.SetDrained(req.NodeAddress, false)
+	}
+	if err := a.checkIdle(); err != nil {
+		return nil, err
 	}
+	member := web.Member{Address: req.NodeAddress, Weight: a.svc.Placement().Weight(req.NodeAddress)}
+	rec, err := a.newRecord("drain", member, req.Throttle)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.svc.SetDrained(req.NodeAddress, true); err != nil {
+		a.dropRecord(rec.ID)
+		return nil, err
+	}
+	job := a.startJob(rec)
+	return &adminpb.DrainNodeResponse{JobId: job.id}, nil
+}
 
-	// remove the node from the list of registered nodes
-	a.svc.DeregisterNode(req.NodeAddress)
-	log.Printf("Removed node %s from the cluster", req.NodeAddress)
-	return &adminpb.RemoveNodeResponse{MigratedFileCount: migratedFileCount}, nil
+// removeDrained drops a drained node whose chunks have all been copied off, there is nothing
+// left to migrate so no job is started. Caller holds a.mu.
+func (a *AdminServer) removeDrained(ctx context.Context, nodeAddr string) (*adminpb.RemoveNodeResponse, error) {
+	without, err := a.svc.MembersWithout(nodeAddr)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := a.offNode(nodeAddr, without)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := a.plan(ctx, a.svc.Placement().List(), rule)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(plan.Moves); n > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "drained node %s still has %d chunk copies to hand over, let its drain job finish", nodeAddr, n)
+	}
+	if err := a.svc.RemoveMember(nodeAddr); err != nil {
+		return nil, err
+	}
+	a.svc.DeregisterNode(nodeAddr)
+	log.Printf("Removed drained node %s from the cluster", nodeAddr)
+	return &adminpb.RemoveNodeResponse{}, nil
 }
 
 func main() {
 	// Define flags
 	port := flag.Int("port", 8080, "Port number for the web server")
 	host := flag.String("host", "localhost", "Host address for the web server")
+	migrateWorkers := flag.Int("migrate-workers", DefaultMigrationWorkers, "Chunks moved at the same time when a node is added or removed")
+	migratePerNode := flag.Int("migrate-per-node", DefaultMigrationPerNode, "Migration reads and deletes in flight against one storage node")
+	migrateBytes := flag.Int64("migrate-bytes-per-sec", 0, "Limit on the bytes all migrations write per second, 0 for none")
+	migrateChunks := flag.Int64("migrate-chunks-per-sec", 0, "Limit on the chunks all migrations move per second, 0 for none")
 
 	// Set custom usage message
 	flag.Usage = printUsage
@@ -203,6 +270,16 @@ func main() {
 		printUsage()
 		return
 	}
+	if *migrateWorkers <= 0 || *migratePerNode <= 0 {
+		fmt.Println("Error: -migrate-workers and -migrate-per-node must be positive")
+		printUsage()
+		return
+	}
+	if *migrateBytes < 0 || *migrateChunks < 0 {
+		fmt.Println("Error: -migrate-bytes-per-sec and -migrate-chunks-per-sec can't be negative")
+		printUsage()
+		return
+	}
 
 	// Construct metadata service
 	var (
@@ -239,7 +316,13 @@ func main() {
 		contentService = web.NewFSVideoContentService(contentServiceOptions)
 	case "nw":
 		// instantiate a network video content service
-		contentService = web.NewNWVideoContentService(contentServiceOptions)
+		// the metadata store also keeps the ring membership so admin changes survive a restart
+		membership, _ := metadataService.(web.MembershipStore)
+		nwService, err := web.NewNWVideoContentService(contentServiceOptions, membership)
+		if err != nil {
+			log.Fatalf("failed to create nw content service: %v", err)
+		}
+		contentService = nwService
 
 		// get listen address for gRPC and HTTP, first part is the admin address
 		adminLstAddr := strings.Split(contentServiceOptions, ",")[0] //
@@ -250,11 +333,24 @@ func main() {
 			log.Fatalf("failed to listen on %s: %v", adminLstAddr, err)
 		}
 
+		// the same store journals migrations, any left unfinished by a crash resume here
+		journal, _ := metadataService.(web.MigrationJournal)
+		admin := NewAdminServer(nwService, metadataService, journal, adminLstAddr, migrationOptions{
+			workers: *migrateWorkers,
+			perNode: *migratePerNode,
+
+			bytesPerSec:  *migrateBytes,
+			chunksPerSec: *migrateChunks,
+		})
+		if err := admin.resumeJournal(); err != nil {
+			log.Printf("failed to load migration journal: %v", err)
+		}
+
 		// start the admin service
 		go func() {
 			// Create your gRPC server
 			grpcServer := grpc.NewServer()
-			adminpb.RegisterVideoContentAdminServiceServer(grpcServer, NewAdminServer(contentService.(*web.NWVideoContentService)))
+			adminpb.RegisterVideoContentAdminServiceServer(grpcServer, admin)
 
 			fmt.Println("[gRPC] Admin server listening on", adminLstAddr)
 			if err := grpcServer.Serve(grpcL); err != nil {
@@ -0,0 +1,508 @@
+package main
+
+// chunk migration helpers shared by the admin RPCs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	adminpb "tritontube/internal/proto"
+	"tritontube/internal/web"
+)
+
+// Migration concurrency defaults, see the -migrate-workers and -migrate-per-node flags.
+const (
+	DefaultMigrationWorkers = 8
+	DefaultMigrationPerNode = 2
+)
+
+// verifyAttempts is how many times a chunk is copied to a node before a
+// checksum mismatch is reported instead of retried.
+const verifyAttempts = 3
+
+// migrationOptions tunes how hard a migration pushes the storage nodes.
+type migrationOptions struct {
+	workers int // chunks moved at the same time
+	perNode int // reads and deletes in flight against any one source node
+
+	bytesPerSec  int64 // starting global limits, SetThrottle changes them later
+	chunksPerSec int64
+}
+
+// nodeLimiter caps how many operations run against each node at once.
+type nodeLimiter struct {
+	limit int
+	mu    sync.Mutex
+	sems  map[string]chan struct{}
+}
+
+func newNodeLimiter(limit int) *nodeLimiter {
+	return &nodeLimiter{limit: limit, sems: make(map[string]chan struct{})}
+}
+
+// acquire waits for a slot on the node, call release when done with it.
+func (l *nodeLimiter) acquire(ctx context.Context, addr string) (release func(), err error) {
+	l.mu.Lock()
+	sem, ok := l.sems[addr]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.sems[addr] = sem
+	}
+	l.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// migrateChunks runs move for every key on a pool of workers. A chunk that fails is
+// recorded on the job and the others carry on; only a cancel stops the migration early.
+func (a *AdminServer) migrateChunks(ctx context.Context, job *migrationJob, keys []string, move func(ctx context.Context, key string) error) error {
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range min(a.opts.workers, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range work {
+				job.scanned.Add(1)
+				if err := move(ctx, key); err != nil && ctx.Err() == nil {
+					job.chunkFailed(key, err)
+				}
+			}
+		}()
+	}
+feed:
+	for _, key := range keys {
+		select {
+		case work <- key:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// chunkInventory records which nodes hold a copy of each chunk, and how big it is.
+type chunkInventory struct {
+	holders map[string][]string
+	sizes   map[string]int64 // missing for nodes that don't report sizes
+}
+
+// keys returns the chunk keys in a stable order so migrations are repeatable.
+func (inv chunkInventory) keys() []string {
+	keys := make([]string, 0, len(inv.holders))
+	for k := range inv.holders {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// splitChunkKey breaks a storage key into the videoId/filename pair the content service takes.
+// The filename may itself contain slashes, so only split on the first one.
+func splitChunkKey(key string) (videoId, filename string, err error) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid chunk name format %q, expected \"videoID/filename\"", key)
+	}
+	return parts[0], parts[1], nil
+}
+
+// inventory lists every chunk on the given nodes.
+func (a *AdminServer) inventory(ctx context.Context, nodeAddrs []string) (chunkInventory, error) {
+	inv := chunkInventory{holders: make(map[string][]string), sizes: make(map[string]int64)}
+	for _, addr := range nodeAddrs {
+		chunkNames, sizes, err := a.svc.ListChunkSizes(ctx, addr)
+		if err != nil {
+			return inv, fmt.Errorf("failed to list chunk names for node %s: %w", addr, err)
+		}
+		for i, chunkName := range chunkNames {
+			inv.holders[chunkName] = append(inv.holders[chunkName], addr)
+			if sizes != nil {
+				inv.sizes[chunkName] = sizes[i]
+			}
+		}
+	}
+	return inv, nil
+}
+
+// chunkOwners decides whether a membership change touches a chunk, and if it does,
+// which nodes should hold it afterwards. Migrations and plans share these.
+type chunkOwners func(key string, holders []string) (owners []string, touch bool, err error)
+
+// changedOwners is the rule for an add: compare the chunk's replica set before and after
+// and only touch the ones that changed.
+func (a *AdminServer) changedOwners(before, after web.Placement) chunkOwners {
+	n := a.svc.ReplicationFactor()
+	return func(key string, holders []string) ([]string, bool, error) {
+		oldOwners, err := before.GetNodesForKey(key, n)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get nodes for chunk %s: %w", key, err)
+		}
+		newOwners, err := after.GetNodesForKey(key, n)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get nodes for chunk %s: %w", key, err)
+		}
+		slices.Sort(oldOwners)
+		changed := !slices.Equal(oldOwners, slices.Sorted(slices.Values(newOwners)))
+		return newOwners, changed, nil
+	}
+}
+
+// ownersOffNode is the rule for a removal: every chunk on the node being removed gets a
+// new replica set, so copy it to whichever owners don't have it yet and delete it from the node.
+func (a *AdminServer) ownersOffNode(nodeAddr string, after web.Placement) chunkOwners {
+	n := a.svc.ReplicationFactor()
+	return func(key string, holders []string) ([]string, bool, error) {
+		if !slices.Contains(holders, nodeAddr) {
+			return nil, false, nil
+		}
+		owners, err := after.GetNodesForKey(key, n)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get nodes for chunk %s: %w", key, err)
+		}
+		return owners, true, nil
+	}
+}
+
+// changedMembers is changedOwners for a change from one membership to another. Only
+// writable members get new copies and the drained ones keep theirs.
+func (a *AdminServer) changedMembers(before, after []web.Member) (chunkOwners, error) {
+	beforePlacement, _, err := a.writablePlacement(before)
+	if err != nil {
+		return nil, err
+	}
+	afterPlacement, drained, err := a.writablePlacement(after)
+	if err != nil {
+		return nil, err
+	}
+	return keepDrained(drained, a.changedOwners(beforePlacement, afterPlacement)), nil
+}
+
+// offNode is ownersOffNode onto the writable members of after, the membership without the node.
+func (a *AdminServer) offNode(nodeAddr string, after []web.Member) (chunkOwners, error) {
+	placement, drained, err := a.writablePlacement(after)
+	if err != nil {
+		return nil, err
+	}
+	return keepDrained(drained, a.ownersOffNode(nodeAddr, placement)), nil
+}
+
+// drainOwners is the rule for a drain: like ownersOffNode every chunk on the node goes to
+// its owners without the node, but the node keeps its copy so reads still find it there.
+func (a *AdminServer) drainOwners(nodeAddr string, without web.Placement) chunkOwners {
+	offNode := a.ownersOffNode(nodeAddr, without)
+	return func(key string, holders []string) ([]string, bool, error) {
+		owners, touch, err := offNode(key, holders)
+		if err != nil || !touch {
+			return nil, false, err
+		}
+		touch = slices.ContainsFunc(owners, func(o string) bool { return !slices.Contains(holders, o) })
+		return append(owners, nodeAddr), touch, nil
+	}
+}
+
+// migration is the body of a migration job. It can run any number of times for the same record:
+// the membership change is made if it hasn't been, then whatever chunks still need it are moved.
+func (a *AdminServer) migration(ctx context.Context, job *migrationJob) error {
+	rec := job.record()
+	if rec.Rollback {
+		return a.rollback(ctx, job, rec)
+	}
+	addr := rec.Member.Address
+	// every node of the old membership, including one being removed, and an added one,
+	// which already holds some chunks if this is a rerun
+	var serverAddrs []string
+	for _, m := range rec.Before {
+		serverAddrs = append(serverAddrs, m.Address)
+	}
+	if rec.Kind == "add" && len(serverAddrs) > 0 {
+		serverAddrs = append(serverAddrs, addr)
+	}
+
+	switch rec.Kind {
+	case "add":
+		if a.svc.Placement().Weight(addr) == 0 {
+			// journaled but the ring change never happened
+			if err := a.svc.AddMember(rec.Member); err != nil {
+				return err
+			}
+		}
+		if len(serverAddrs) == 0 {
+			// first node in the cluster, nothing to migrate
+			return nil
+		}
+		rule, err := a.changedMembers(rec.Before, a.svc.Members())
+		if err != nil {
+			return err
+		}
+		return a.migrate(ctx, job, serverAddrs, rule)
+
+	case "rebalance":
+		return a.rebalance(ctx, job)
+
+	case "drain":
+		if !a.svc.Drained(addr) {
+			if err := a.svc.SetDrained(addr, true); err != nil {
+				return err
+			}
+		}
+		members, err := a.svc.MembersWithout(addr)
+		if err != nil {
+			return err
+		}
+		without, drained, err := a.writablePlacement(members)
+		if err != nil {
+			return err
+		}
+		return a.migrate(ctx, job, a.svc.Placement().List(), keepDrained(drained, a.drainOwners(addr, without)))
+
+	case "remove":
+		if a.svc.Placement().Weight(addr) > 0 {
+			if err := a.svc.RemoveMember(addr); err != nil {
+				return err
+			}
+		}
+		a.svc.RegisterNode(addr) // gone after a restart
+		rule, err := a.offNode(addr, a.svc.Members())
+		if err != nil {
+			return err
+		}
+		if err := a.migrate(ctx, job, serverAddrs, rule); err != nil {
+			return err
+		}
+		if n := job.failed.Load(); n > 0 {
+			// keep the connection so the chunks left behind can still be copied off
+			log.Printf("Node %s still holds %d chunks, keeping its connection", addr, n)
+			return nil
+		}
+		// remove the node from the list of registered nodes
+		a.svc.DeregisterNode(addr)
+		log.Printf("Removed node %s from the cluster", addr)
+		return nil
+	}
+	return fmt.Errorf("unknown migration kind %q", rec.Kind)
+}
+
+// rollback undoes the membership change in rec: an added node comes off the ring again, a
+// removed one goes back with its weight, then every chunk whose owners differ with and without
+// the change is moved back, the same way migration moves them forward. Only this job's change
+// is inverted, against the membership as it is now, so nodes another web server added or
+// removed in the meantime stay. Like migration it can run again after a failure or a restart.
+func (a *AdminServer) rollback(ctx context.Context, job *migrationJob, rec web.MigrationRecord) error {
+	addr := rec.Member.Address
+	onRing := a.svc.Placement().Weight(addr) > 0
+	switch {
+	case rec.Kind == "add" && onRing:
+		if err := a.svc.RemoveMember(addr); err != nil {
+			return err
+		}
+	case rec.Kind == "remove" && !onRing:
+		if err := a.svc.AddMember(rec.Member); err != nil {
+			return err
+		}
+	}
+	a.svc.RegisterNode(addr) // an added node is off the ring again, keep reading from it
+
+	// the membership now, and what it would be with the change made
+	reverted := a.svc.Members()
+	changed := slices.DeleteFunc(slices.Clone(reverted), func(m web.Member) bool { return m.Address == addr })
+	if rec.Kind == "add" {
+		changed = append(changed, rec.Member)
+	}
+	rule, err := a.changedMembers(changed, reverted)
+	if err != nil {
+		return err
+	}
+	var serverAddrs []string
+	for _, m := range reverted {
+		serverAddrs = append(serverAddrs, m.Address)
+	}
+	if rec.Kind == "add" {
+		serverAddrs = append(serverAddrs, addr)
+	}
+	if err := a.migrate(ctx, job, serverAddrs, rule); err != nil {
+		return err
+	}
+	if rec.Kind == "add" && job.failed.Load() == 0 {
+		a.svc.DeregisterNode(addr)
+	}
+	log.Printf("Rolled back %s of node %s", rec.Kind, addr)
+	return nil
+}
+
+// migrate lists every chunk on serverAddrs and relocates the ones the rule touches.
+func (a *AdminServer) migrate(ctx context.Context, job *migrationJob, serverAddrs []string, rule chunkOwners) error {
+	// read all names from every server
+	inv, err := a.inventory(ctx, serverAddrs)
+	if err != nil {
+		return err
+	}
+	return a.migrateInventory(ctx, job, inv, rule)
+}
+
+// migrateInventory relocates the chunks of inv the rule touches.
+func (a *AdminServer) migrateInventory(ctx context.Context, job *migrationJob, inv chunkInventory, rule chunkOwners) error {
+	return a.migrateChunks(ctx, job, inv.keys(), func(ctx context.Context, chunkName string) error {
+		holders := inv.holders[chunkName]
+		owners, touch, err := rule(chunkName, holders)
+		if err != nil || !touch {
+			// leave chunk where it is
+			return err
+		}
+		return a.relocate(ctx, job, chunkName, holders, owners)
+	})
+}
+
+// plan works out what migrate would do with the chunks as they are now, without moving any.
+func (a *AdminServer) plan(ctx context.Context, serverAddrs []string, rule chunkOwners) (*adminpb.MigrationPlan, error) {
+	inv, err := a.inventory(ctx, serverAddrs)
+	if err != nil {
+		return nil, err
+	}
+	return planInventory(inv, rule)
+}
+
+func planInventory(inv chunkInventory, rule chunkOwners) (*adminpb.MigrationPlan, error) {
+	plan := &adminpb.MigrationPlan{}
+	for _, key := range inv.keys() {
+		holders := inv.holders[key]
+		owners, touch, err := rule(key, holders)
+		if err != nil {
+			return nil, err
+		}
+		if !touch {
+			continue
+		}
+		// same steps as relocate, which reads from the first holder that answers
+		for _, to := range owners {
+			if slices.Contains(holders, to) {
+				continue
+			}
+			plan.Moves = append(plan.Moves, &adminpb.ChunkMove{
+				Key:         key,
+				Source:      holders[0],
+				Destination: to,
+				Bytes:       inv.sizes[key],
+			})
+			plan.TotalBytes += inv.sizes[key]
+		}
+		for _, from := range holders {
+			if !slices.Contains(owners, from) {
+				plan.Deletions = append(plan.Deletions, &adminpb.ChunkDeletion{Key: key, Node: from})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// relocate makes the chunk's holders match its owners: the owners missing a copy get one,
+// then every holder that isn't an owner anymore drops its copy. Copies written are counted on the job.
+// Reads and deletes wait for a slot on the node they hit, see migrationOptions.perNode.
+func (a *AdminServer) relocate(ctx context.Context, job *migrationJob, key string, holders, owners []string) error {
+	videoId, filename, err := splitChunkKey(key)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var sum [sha256.Size]byte
+	for _, to := range owners {
+		if slices.Contains(holders, to) {
+			continue // already has it
+		}
+		if data == nil {
+			if err := a.throttled(ctx, job, 1, 0); err != nil {
+				return err
+			}
+			if data, err = a.readAny(ctx, videoId, filename, holders); err != nil {
+				return err
+			}
+			sum = sha256.Sum256(data)
+		}
+		if err := a.throttled(ctx, job, 0, int64(len(data))); err != nil {
+			return err
+		}
+		if err := a.copyVerified(ctx, videoId, filename, data, sum[:], to); err != nil {
+			return err
+		}
+		log.Printf("Migrated chunk %s to node %s", key, to)
+		job.moved.Add(1)
+		job.bytesMoved.Add(int64(len(data)))
+	}
+
+	// Now delete the file from the nodes that no longer own it, every new copy
+	// has been checked by now so the source can go
+	for _, from := range holders {
+		if slices.Contains(owners, from) {
+			continue
+		}
+		release, err := a.sources.acquire(ctx, from)
+		if err != nil {
+			return err
+		}
+		err = a.svc.DeleteFile(ctx, videoId, filename, from)
+		release()
+		if err != nil {
+			return fmt.Errorf("failed to delete chunk %s from node %s: %w", key, from, err)
+		}
+	}
+	return nil
+}
+
+// copyVerified writes the chunk to the node and has the node hash what it stored.
+// A copy that doesn't match sum is written again, up to verifyAttempts times, and
+// if it still doesn't match it is deleted so nobody reads it and an error is returned.
+func (a *AdminServer) copyVerified(ctx context.Context, videoId, filename string, data, sum []byte, to string) error {
+	key := web.ComposeKey(videoId, filename)
+	var got []byte
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.svc.WriteToNode(ctx, videoId, filename, data, to); err != nil {
+			return fmt.Errorf("failed to write chunk %s to node %s: %w", key, to, err)
+		}
+		var err error
+		got, err = a.svc.ChecksumOnNode(ctx, videoId, filename, to)
+		if err != nil {
+			return fmt.Errorf("failed to verify chunk %s on node %s: %w", key, to, err)
+		}
+		if bytes.Equal(got, sum) {
+			return nil
+		}
+		log.Printf("Chunk %s on node %s has sha256 %x, want %x (attempt %d of %d)", key, to, got, sum, attempt, verifyAttempts)
+	}
+	if err := a.svc.DeleteFile(ctx, videoId, filename, to); err != nil {
+		log.Printf("Failed to delete bad copy of chunk %s from node %s: %v", key, to, err)
+	}
+	return fmt.Errorf("chunk %s on node %s has sha256 %x after %d copies, want %x", key, to, got, verifyAttempts, sum)
+}
+
+// readAny reads the chunk from the first holder that answers.
+func (a *AdminServer) readAny(ctx context.Context, videoId, filename string, holders []string) ([]byte, error) {
+	var lastErr error
+	for _, from := range holders {
+		release, err := a.sources.acquire(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		data, err := a.svc.ReadFromNode(ctx, videoId, filename, from)
+		release()
+		if err == nil {
+			return data, nil
+		}
+		lastErr = fmt.Errorf("failed to read chunk %s from node %s: %w", web.ComposeKey(videoId, filename), from, err)
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,174 @@
+package main
+
+// Rebalance puts every chunk back where the placement says it belongs, after crashes
+// or manual fixes left chunks on nodes that don't own them
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	adminpb "tritontube/internal/proto"
+	"tritontube/internal/web"
+)
+
+// chunkAudit is what a rebalance reports on top of the moves. Orphans are only reported,
+// deleting them is left to the operator.
+type chunkAudit struct {
+	duplicates int64    // chunks with more copies than replicas
+	orphans    int64    // chunks of videos the metadata service doesn't know
+	orphanKeys []string // the first maxChunkErrors of them
+}
+
+// audit looks for duplicates and orphans in the inventory. Copies on drained nodes are
+// expected on top of the replicas and don't make a chunk a duplicate.
+func (a *AdminServer) audit(inv chunkInventory, drained []string) (chunkAudit, error) {
+	var audit chunkAudit
+	var videos map[string]bool
+	if a.metadata != nil {
+		list, err := a.metadata.List()
+		if err != nil {
+			return audit, fmt.Errorf("failed to list videos: %w", err)
+		}
+		videos = make(map[string]bool, len(list))
+		for _, v := range list {
+			videos[v.Id] = true
+		}
+	}
+	for _, key := range inv.keys() {
+		holders := slices.DeleteFunc(slices.Clone(inv.holders[key]), func(h string) bool { return slices.Contains(drained, h) })
+		if len(holders) > a.svc.ReplicationFactor() {
+			audit.duplicates++
+		}
+		videoId, _, _ := strings.Cut(key, "/")
+		if videos != nil && !videos[videoId] {
+			audit.orphans++
+			if len(audit.orphanKeys) < maxChunkErrors {
+				audit.orphanKeys = append(audit.orphanKeys, key)
+			}
+		}
+	}
+	return audit, nil
+}
+
+// placedOwners is the rule for a rebalance: every chunk belongs on exactly its owners
+// under the placement, touch the ones held anywhere else or missing from an owner.
+// The placement must leave out drained nodes, which take no new copies. Like with
+// drainOwners a drained node keeps the copies it has, so reads still find them there.
+func (a *AdminServer) placedOwners(placement web.Placement, drained []string) chunkOwners {
+	n := a.svc.ReplicationFactor()
+	return func(key string, holders []string) ([]string, bool, error) {
+		owners, err := placement.GetNodesForKey(key, n)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get nodes for chunk %s: %w", key, err)
+		}
+		owners = withDrained(owners, holders, drained)
+		placed := slices.Equal(slices.Sorted(slices.Values(holders)), slices.Sorted(slices.Values(owners)))
+		return owners, !placed, nil
+	}
+}
+
+// keepDrained wraps a rule so the chunks it touches stay on the drained nodes already holding them.
+func keepDrained(drained []string, rule chunkOwners) chunkOwners {
+	return func(key string, holders []string) ([]string, bool, error) {
+		owners, touch, err := rule(key, holders)
+		if err != nil || !touch {
+			return owners, touch, err
+		}
+		return withDrained(owners, holders, drained), true, nil
+	}
+}
+
+// withDrained adds the drained holders of a chunk to its owners, a drained node keeps its copies
+// for reads until it is removed.
+func withDrained(owners, holders, drained []string) []string {
+	for _, addr := range drained {
+		if slices.Contains(holders, addr) && !slices.Contains(owners, addr) {
+			owners = append(owners, addr)
+		}
+	}
+	return owners
+}
+
+// writablePlacement is the placement of members without the drained ones, the one Write picks
+// new replicas from, and the drained members it leaves out. Every plan that picks
+// destinations uses it.
+func (a *AdminServer) writablePlacement(members []web.Member) (web.Placement, []string, error) {
+	var writable []web.Member
+	var drained []string
+	for _, m := range members {
+		if m.Drained {
+			drained = append(drained, m.Address)
+		} else {
+			writable = append(writable, m)
+		}
+	}
+	placement, err := a.svc.NewPlacement(writable)
+	if err != nil {
+		return nil, nil, err
+	}
+	return placement, drained, nil
+}
+
+func (a *AdminServer) Rebalance(ctx context.Context, req *adminpb.RebalanceRequest) (*adminpb.RebalanceResponse, error) {
+	if req.PlanOnly {
+		writable, drained, err := a.writablePlacement(a.svc.Members())
+		if err != nil {
+			return nil, err
+		}
+		inv, err := a.inventory(ctx, a.svc.Placement().List())
+		if err != nil {
+			return nil, err
+		}
+		plan, err := planInventory(inv, a.placedOwners(writable, drained))
+		if err != nil {
+			return nil, err
+		}
+		audit, err := a.audit(inv, drained)
+		if err != nil {
+			return nil, err
+		}
+		return &adminpb.RebalanceResponse{
+			Plan:       plan,
+			Duplicates: audit.duplicates,
+			Orphans:    audit.orphans,
+			OrphanKeys: audit.orphanKeys,
+		}, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.checkIdle(); err != nil {
+		return nil, err
+	}
+	rec, err := a.newRecord("rebalance", web.Member{}, req.Throttle)
+	if err != nil {
+		return nil, err
+	}
+	job := a.startJob(rec)
+	return &adminpb.RebalanceResponse{JobId: job.id}, nil
+}
+
+// rebalance is the body of a rebalance job.
+func (a *AdminServer) rebalance(ctx context.Context, job *migrationJob) error {
+	writable, drained, err := a.writablePlacement(a.svc.Members())
+	if err != nil {
+		return err
+	}
+	inv, err := a.inventory(ctx, a.svc.Placement().List())
+	if err != nil {
+		return err
+	}
+	audit, err := a.audit(inv, drained)
+	if err != nil {
+		return err
+	}
+	job.mu.Lock()
+	job.audit = audit
+	job.mu.Unlock()
+	if audit.duplicates > 0 || audit.orphans > 0 {
+		log.Printf("Rebalance %s: %d duplicated chunks, %d orphaned chunks", job.id, audit.duplicates, audit.orphans)
+	}
+	return a.migrateInventory(ctx, job, inv, a.placedOwners(writable, drained))
+}
@@ -0,0 +1,63 @@
+package main
+
+// ClusterStats puts what each storage node holds next to its share of the ring,
+// so an uneven spread shows up without listing every chunk
+
+import (
+	"context"
+	"sync"
+	adminpb "tritontube/internal/proto"
+)
+
+func (a *AdminServer) ClusterStats(ctx context.Context, req *adminpb.ClusterStatsRequest) (*adminpb.ClusterStatsResponse, error) {
+	placement := a.svc.Placement()
+	nodes := placement.List()
+	shares := placement.Shares()
+
+	// ask every node at once, one slow node shouldn't hold up the rest
+	stats := make([]*adminpb.NodeStats, len(nodes))
+	var wg sync.WaitGroup
+	for i, addr := range nodes {
+		stats[i] = &adminpb.NodeStats{
+			Address:       addr,
+			Weight:        int32(placement.Weight(addr)),
+			KeyspaceShare: shares[addr],
+			Drained:       a.svc.Drained(addr),
+			FreeBytes:     -1,
+			DiskBytes:     -1,
+		}
+		wg.Add(1)
+		go func(ns *adminpb.NodeStats) {
+			defer wg.Done()
+			resp, err := a.svc.NodeStats(ctx, ns.Address)
+			if err != nil {
+				ns.Error = err.Error()
+				return
+			}
+			ns.FileCount = resp.FileCount
+			ns.TotalBytes = resp.TotalBytes
+			ns.FreeBytes = resp.FreeBytes
+			ns.DiskBytes = resp.DiskBytes
+			for _, v := range resp.Videos {
+				ns.Videos = append(ns.Videos, &adminpb.VideoStats{
+					VideoId:    v.VideoId,
+					FileCount:  v.FileCount,
+					TotalBytes: v.TotalBytes,
+				})
+			}
+		}(stats[i])
+	}
+	wg.Wait()
+
+	response := &adminpb.ClusterStatsResponse{Nodes: stats}
+	for _, ns := range stats {
+		response.FileCount += ns.FileCount
+		response.TotalBytes += ns.TotalBytes
+	}
+	if response.TotalBytes > 0 {
+		for _, ns := range stats {
+			ns.ByteShare = float64(ns.TotalBytes) / float64(response.TotalBytes)
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+// speed limits for migrations, so moving chunks during the day doesn't starve playback
+
+import (
+	"context"
+	"sync"
+	"time"
+	adminpb "tritontube/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rateLimiter is a token bucket whose rate can change while callers wait on it.
+// A rate of 0 lets everything through.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second, the bucket holds at most one second's worth
+	tokens  float64 // goes negative when a request is bigger than the bucket
+	last    time.Time
+	changed chan struct{} // closed when the rate changes, so waiters recompute
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	l := &rateLimiter{changed: make(chan struct{})}
+	l.setRate(rate)
+	return l
+}
+
+func (l *rateLimiter) limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// setRate changes the rate, callers already waiting pick it up right away.
+func (l *rateLimiter) setRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	if l.rate == 0 || rate == 0 {
+		l.tokens = float64(rate) // start with a full bucket
+	}
+	l.rate = float64(rate)
+	l.tokens = min(l.tokens, l.rate)
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// refill adds the tokens earned since last. Caller holds l.mu.
+func (l *rateLimiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	}
+	l.last = now
+}
+
+// wait takes n tokens, blocking until the bucket has them. A request bigger than the
+// bucket waits for a full one and leaves it in debt, so the next requests pay it off.
+func (l *rateLimiter) wait(ctx context.Context, n int64) error {
+	l.mu.Lock()
+	for {
+		if l.rate == 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		l.refill(time.Now())
+		need := min(float64(n), l.rate)
+		if l.tokens >= need {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+		changed := l.changed
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		l.mu.Lock()
+	}
+}
+
+// throttle is a bytes and a chunks per second limit, for one job or for all of them.
+type throttle struct {
+	bytes  *rateLimiter
+	chunks *rateLimiter
+}
+
+func newThrottle(bytesPerSec, chunksPerSec int64) *throttle {
+	return &throttle{bytes: newRateLimiter(bytesPerSec), chunks: newRateLimiter(chunksPerSec)}
+}
+
+func (t *throttle) set(bytesPerSec, chunksPerSec int64) {
+	t.bytes.setRate(bytesPerSec)
+	t.chunks.setRate(chunksPerSec)
+}
+
+// wait blocks until the limits allow moving that many chunks and bytes.
+func (t *throttle) wait(ctx context.Context, chunks, bytes int64) error {
+	if chunks > 0 {
+		if err := t.chunks.wait(ctx, chunks); err != nil {
+			return err
+		}
+	}
+	if bytes > 0 {
+		return t.bytes.wait(ctx, bytes)
+	}
+	return nil
+}
+
+func (t *throttle) proto() *adminpb.Throttle {
+	return &adminpb.Throttle{BytesPerSecond: t.bytes.limit(), ChunksPerSecond: t.chunks.limit()}
+}
+
+// checkThrottle validates limits from a request, unset means no limits.
+func checkThrottle(pb *adminpb.Throttle) (bytesPerSec, chunksPerSec int64, err error) {
+	if pb.GetBytesPerSecond() < 0 || pb.GetChunksPerSecond() < 0 {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "throttle limits can't be negative")
+	}
+	return pb.GetBytesPerSecond(), pb.GetChunksPerSecond(), nil
+}
+
+// throttled waits until both the job's limits and the global ones allow the traffic.
+func (a *AdminServer) throttled(ctx context.Context, job *migrationJob, chunks, bytes int64) error {
+	if err := job.throttle.wait(ctx, chunks, bytes); err != nil {
+		return err
+	}
+	return a.throttle.wait(ctx, chunks, bytes)
+}
+
+// SetThrottle changes the global limits, or those of one job. A job's limits are journaled
+// with it so a resumed job keeps them, the global ones last until the web server restarts.
+func (a *AdminServer) SetThrottle(ctx context.Context, req *adminpb.SetThrottleRequest) (*adminpb.SetThrottleResponse, error) {
+	bytesPerSec, chunksPerSec, err := checkThrottle(req.Throttle)
+	if err != nil {
+		return nil, err
+	}
+	if req.JobId == "" {
+		a.throttle.set(bytesPerSec, chunksPerSec)
+		return &adminpb.SetThrottleResponse{GlobalThrottle: a.throttle.proto()}, nil
+	}
+
+	job, err := a.findJob(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	job.throttle.set(bytesPerSec, chunksPerSec)
+	if err := job.saveThrottle(a, bytesPerSec, chunksPerSec); err != nil {
+		return nil, err
+	}
+	return &adminpb.SetThrottleResponse{GlobalThrottle: a.throttle.proto(), Job: job.proto()}, nil
+}
+
+// saveThrottle puts the job's new limits in its journal record. A finished job has
+// no record left, so only jobs that can still run are saved.
+func (j *migrationJob) saveThrottle(a *AdminServer, bytesPerSec, chunksPerSec int64) error {
+	// under j.mu so the job can't finish and drop its record in between
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.rec.BytesPerSec, j.rec.ChunksPerSec = bytesPerSec, chunksPerSec
+	if j.state == jobDone || j.state == jobRolledBack {
+		return nil
+	}
+	rec := j.rec
+	if j.state != jobRunning && j.state != jobRollingBack {
+		rec.State, rec.Error = j.state, ""
+		if j.err != nil {
+			rec.Error = j.err.Error()
+		}
+	}
+	return a.saveRecord(rec)
+}
@@ -9,6 +9,7 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,8 +23,15 @@ const (
 )
 
 type AddNodeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	// Relative capacity of the node, a node of weight 2 gets about twice the chunks of weight 1.
+	// Zero means 1, negative weights and weights above 100 are refused.
+	Weight int32 `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	// Only work out which chunks would move and return that as the plan,
+	// the ring and the data are left alone and no job is started.
+	PlanOnly      bool      `protobuf:"varint,3,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"`
+	Throttle      *Throttle `protobuf:"bytes,4,opt,name=throttle,proto3" json:"throttle,omitempty"` // limits for the migration job, unset means none beyond the global ones
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,9 +73,32 @@ func (x *AddNodeRequest) GetNodeAddress() string {
 	return ""
 }
 
+func (x *AddNodeRequest) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *AddNodeRequest) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *AddNodeRequest) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
 type AddNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
-	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
+	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"` // always 0 now, the count is on the job
+	JobId             string                 `protobuf:"bytes,2,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`                                        // migration job started for the add, see GetJob
+	Plan              *MigrationPlan         `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`                                                       // set for plan_only requests
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -109,9 +140,25 @@ func (x *AddNodeResponse) GetMigratedFileCount() int32 {
 	return 0
 }
 
+func (x *AddNodeResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *AddNodeResponse) GetPlan() *MigrationPlan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
 type RemoveNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	PlanOnly      bool                   `protobuf:"varint,2,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"` // see AddNodeRequest.plan_only
+	Throttle      *Throttle              `protobuf:"bytes,3,opt,name=throttle,proto3" json:"throttle,omitempty"`                  // see AddNodeRequest.throttle
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,9 +200,25 @@ func (x *RemoveNodeRequest) GetNodeAddress() string {
 	return ""
 }
 
+func (x *RemoveNodeRequest) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *RemoveNodeRequest) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
 type RemoveNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
-	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
+	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"` // always 0 now, the count is on the job
+	JobId             string                 `protobuf:"bytes,2,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`                                        // migration job started for the removal, see GetJob
+	Plan              *MigrationPlan         `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`                                                       // set for plan_only requests
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -197,6 +260,201 @@ func (x *RemoveNodeResponse) GetMigratedFileCount() int32 {
 	return 0
 }
 
+func (x *RemoveNodeResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *RemoveNodeResponse) GetPlan() *MigrationPlan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+// MigrationPlan is what a membership change would do to the chunks as they are right now.
+type MigrationPlan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Moves         []*ChunkMove           `protobuf:"bytes,1,rep,name=moves,proto3" json:"moves,omitempty"`                              // copies to write
+	Deletions     []*ChunkDeletion       `protobuf:"bytes,2,rep,name=deletions,proto3" json:"deletions,omitempty"`                      // copies to drop once the new owners have them
+	TotalBytes    int64                  `protobuf:"varint,3,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"` // sum of the moves' bytes
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrationPlan) Reset() {
+	*x = MigrationPlan{}
+	mi := &file_proto_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrationPlan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrationPlan) ProtoMessage() {}
+
+func (x *MigrationPlan) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrationPlan.ProtoReflect.Descriptor instead.
+func (*MigrationPlan) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MigrationPlan) GetMoves() []*ChunkMove {
+	if x != nil {
+		return x.Moves
+	}
+	return nil
+}
+
+func (x *MigrationPlan) GetDeletions() []*ChunkDeletion {
+	if x != nil {
+		return x.Deletions
+	}
+	return nil
+}
+
+func (x *MigrationPlan) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+type ChunkMove struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`           // node the chunk is read from
+	Destination   string                 `protobuf:"bytes,3,opt,name=destination,proto3" json:"destination,omitempty"` // new owner it is written to
+	Bytes         int64                  `protobuf:"varint,4,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkMove) Reset() {
+	*x = ChunkMove{}
+	mi := &file_proto_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkMove) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkMove) ProtoMessage() {}
+
+func (x *ChunkMove) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkMove.ProtoReflect.Descriptor instead.
+func (*ChunkMove) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ChunkMove) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ChunkMove) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ChunkMove) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *ChunkMove) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+type ChunkDeletion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Node          string                 `protobuf:"bytes,2,opt,name=node,proto3" json:"node,omitempty"` // holder that doesn't own the chunk anymore
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkDeletion) Reset() {
+	*x = ChunkDeletion{}
+	mi := &file_proto_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkDeletion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkDeletion) ProtoMessage() {}
+
+func (x *ChunkDeletion) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkDeletion.ProtoReflect.Descriptor instead.
+func (*ChunkDeletion) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ChunkDeletion) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ChunkDeletion) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
 type ListNodesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -205,7 +463,7 @@ type ListNodesRequest struct {
 
 func (x *ListNodesRequest) Reset() {
 	*x = ListNodesRequest{}
-	mi := &file_proto_admin_proto_msgTypes[4]
+	mi := &file_proto_admin_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -217,7 +475,7 @@ func (x *ListNodesRequest) String() string {
 func (*ListNodesRequest) ProtoMessage() {}
 
 func (x *ListNodesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_admin_proto_msgTypes[4]
+	mi := &file_proto_admin_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -230,19 +488,20 @@ func (x *ListNodesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNodesRequest.ProtoReflect.Descriptor instead.
 func (*ListNodesRequest) Descriptor() ([]byte, []int) {
-	return file_proto_admin_proto_rawDescGZIP(), []int{4}
+	return file_proto_admin_proto_rawDescGZIP(), []int{7}
 }
 
 type ListNodesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Nodes         []string               `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	NodeInfo      []*NodeInfo            `protobuf:"bytes,2,rep,name=node_info,json=nodeInfo,proto3" json:"node_info,omitempty"` // same nodes, with their placement details
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ListNodesResponse) Reset() {
 	*x = ListNodesResponse{}
-	mi := &file_proto_admin_proto_msgTypes[5]
+	mi := &file_proto_admin_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -254,7 +513,7 @@ func (x *ListNodesResponse) String() string {
 func (*ListNodesResponse) ProtoMessage() {}
 
 func (x *ListNodesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_admin_proto_msgTypes[5]
+	mi := &file_proto_admin_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -267,7 +526,7 @@ func (x *ListNodesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNodesResponse.ProtoReflect.Descriptor instead.
 func (*ListNodesResponse) Descriptor() ([]byte, []int) {
-	return file_proto_admin_proto_rawDescGZIP(), []int{5}
+	return file_proto_admin_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ListNodesResponse) GetNodes() []string {
@@ -277,28 +536,1519 @@ func (x *ListNodesResponse) GetNodes() []string {
 	return nil
 }
 
-var File_proto_admin_proto protoreflect.FileDescriptor
+func (x *ListNodesResponse) GetNodeInfo() []*NodeInfo {
+	if x != nil {
+		return x.NodeInfo
+	}
+	return nil
+}
 
-const file_proto_admin_proto_rawDesc = "" +
-	"\n" +
-	"\x11proto/admin.proto\x12\n" +
-	"tritontube\"3\n" +
-	"\x0eAddNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"A\n" +
-	"\x0fAddNodeResponse\x12.\n" +
-	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"6\n" +
-	"\x11RemoveNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"D\n" +
-	"\x12RemoveNodeResponse\x12.\n" +
-	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"\x12\n" +
-	"\x10ListNodesRequest\")\n" +
-	"\x11ListNodesResponse\x12\x14\n" +
-	"\x05nodes\x18\x01 \x03(\tR\x05nodes2\xf5\x01\n" +
+type NodeInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	KeyspaceShare float64                `protobuf:"fixed64,3,opt,name=keyspace_share,json=keyspaceShare,proto3" json:"keyspace_share,omitempty"` // fraction of the hash space this node owns, 0..1
+	Health        string                 `protobuf:"bytes,4,opt,name=health,proto3" json:"health,omitempty"`                                      // up, suspect or down, from the web server's health checker
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`                  // last successful health probe, unset if never
+	Drained       bool                   `protobuf:"varint,6,opt,name=drained,proto3" json:"drained,omitempty"`                                   // takes no new writes, see DrainNode
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeInfo) Reset() {
+	*x = NodeInfo{}
+	mi := &file_proto_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeInfo) ProtoMessage() {}
+
+func (x *NodeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeInfo.ProtoReflect.Descriptor instead.
+func (*NodeInfo) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *NodeInfo) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *NodeInfo) GetKeyspaceShare() float64 {
+	if x != nil {
+		return x.KeyspaceShare
+	}
+	return 0
+}
+
+func (x *NodeInfo) GetHealth() string {
+	if x != nil {
+		return x.Health
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetDrained() bool {
+	if x != nil {
+		return x.Drained
+	}
+	return false
+}
+
+type ClusterStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClusterStatsRequest) Reset() {
+	*x = ClusterStatsRequest{}
+	mi := &file_proto_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClusterStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterStatsRequest) ProtoMessage() {}
+
+func (x *ClusterStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterStatsRequest.ProtoReflect.Descriptor instead.
+func (*ClusterStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{10}
+}
+
+type ClusterStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*NodeStats           `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	FileCount     int64                  `protobuf:"varint,2,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"` // over all nodes, replicas count once per copy
+	TotalBytes    int64                  `protobuf:"varint,3,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClusterStatsResponse) Reset() {
+	*x = ClusterStatsResponse{}
+	mi := &file_proto_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClusterStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterStatsResponse) ProtoMessage() {}
+
+func (x *ClusterStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterStatsResponse.ProtoReflect.Descriptor instead.
+func (*ClusterStatsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ClusterStatsResponse) GetNodes() []*NodeStats {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *ClusterStatsResponse) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *ClusterStatsResponse) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+type NodeStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	KeyspaceShare float64                `protobuf:"fixed64,3,opt,name=keyspace_share,json=keyspaceShare,proto3" json:"keyspace_share,omitempty"` // fraction of the hash space this node owns, 0..1
+	Drained       bool                   `protobuf:"varint,4,opt,name=drained,proto3" json:"drained,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"` // why the node couldn't be asked, the numbers below are zero then
+	FileCount     int64                  `protobuf:"varint,6,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	TotalBytes    int64                  `protobuf:"varint,7,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	ByteShare     float64                `protobuf:"fixed64,8,opt,name=byte_share,json=byteShare,proto3" json:"byte_share,omitempty"` // fraction of the cluster's bytes on this node, compare with keyspace_share
+	FreeBytes     int64                  `protobuf:"varint,9,opt,name=free_bytes,json=freeBytes,proto3" json:"free_bytes,omitempty"`  // -1 if the node doesn't know
+	DiskBytes     int64                  `protobuf:"varint,10,opt,name=disk_bytes,json=diskBytes,proto3" json:"disk_bytes,omitempty"` // -1 if the node doesn't know
+	Videos        []*VideoStats          `protobuf:"bytes,11,rep,name=videos,proto3" json:"videos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeStats) Reset() {
+	*x = NodeStats{}
+	mi := &file_proto_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeStats) ProtoMessage() {}
+
+func (x *NodeStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeStats.ProtoReflect.Descriptor instead.
+func (*NodeStats) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *NodeStats) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NodeStats) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *NodeStats) GetKeyspaceShare() float64 {
+	if x != nil {
+		return x.KeyspaceShare
+	}
+	return 0
+}
+
+func (x *NodeStats) GetDrained() bool {
+	if x != nil {
+		return x.Drained
+	}
+	return false
+}
+
+func (x *NodeStats) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *NodeStats) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *NodeStats) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *NodeStats) GetByteShare() float64 {
+	if x != nil {
+		return x.ByteShare
+	}
+	return 0
+}
+
+func (x *NodeStats) GetFreeBytes() int64 {
+	if x != nil {
+		return x.FreeBytes
+	}
+	return 0
+}
+
+func (x *NodeStats) GetDiskBytes() int64 {
+	if x != nil {
+		return x.DiskBytes
+	}
+	return 0
+}
+
+func (x *NodeStats) GetVideos() []*VideoStats {
+	if x != nil {
+		return x.Videos
+	}
+	return nil
+}
+
+type VideoStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VideoId       string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	FileCount     int64                  `protobuf:"varint,2,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	TotalBytes    int64                  `protobuf:"varint,3,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VideoStats) Reset() {
+	*x = VideoStats{}
+	mi := &file_proto_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VideoStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VideoStats) ProtoMessage() {}
+
+func (x *VideoStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VideoStats.ProtoReflect.Descriptor instead.
+func (*VideoStats) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *VideoStats) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+func (x *VideoStats) GetFileCount() int64 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *VideoStats) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+type DrainNodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	Undrain       bool                   `protobuf:"varint,2,opt,name=undrain,proto3" json:"undrain,omitempty"`  // put the node back in write rotation instead, no job is started
+	Throttle      *Throttle              `protobuf:"bytes,3,opt,name=throttle,proto3" json:"throttle,omitempty"` // see AddNodeRequest.throttle
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainNodeRequest) Reset() {
+	*x = DrainNodeRequest{}
+	mi := &file_proto_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainNodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainNodeRequest) ProtoMessage() {}
+
+func (x *DrainNodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainNodeRequest.ProtoReflect.Descriptor instead.
+func (*DrainNodeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DrainNodeRequest) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *DrainNodeRequest) GetUndrain() bool {
+	if x != nil {
+		return x.Undrain
+	}
+	return false
+}
+
+func (x *DrainNodeRequest) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
+type DrainNodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainNodeResponse) Reset() {
+	*x = DrainNodeResponse{}
+	mi := &file_proto_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainNodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainNodeResponse) ProtoMessage() {}
+
+func (x *DrainNodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainNodeResponse.ProtoReflect.Descriptor instead.
+func (*DrainNodeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DrainNodeResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`                                         // add, remove, rebalance or drain
+	NodeAddress   string                 `protobuf:"bytes,3,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`        // node being added or removed
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`                                       // running, done, failed, canceled, rolling_back or rolled_back
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`                                       // why the job failed, empty otherwise
+	ChunksScanned int64                  `protobuf:"varint,6,opt,name=chunks_scanned,json=chunksScanned,proto3" json:"chunks_scanned,omitempty"` // chunks looked at so far
+	ChunksMoved   int64                  `protobuf:"varint,7,opt,name=chunks_moved,json=chunksMoved,proto3" json:"chunks_moved,omitempty"`       // copies written to new owners
+	ChunksFailed  int64                  `protobuf:"varint,8,opt,name=chunks_failed,json=chunksFailed,proto3" json:"chunks_failed,omitempty"`    // chunks that couldn't be moved
+	BytesMoved    int64                  `protobuf:"varint,9,opt,name=bytes_moved,json=bytesMoved,proto3" json:"bytes_moved,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=finished,proto3" json:"finished,omitempty"`                          // unset while running
+	ChunkErrors   []*ChunkError          `protobuf:"bytes,12,rep,name=chunk_errors,json=chunkErrors,proto3" json:"chunk_errors,omitempty"` // the first chunks that couldn't be moved
+	// The job is undoing its membership change after an abort. The chunk
+	// counts start over for the moves back, error says why it is rolling back.
+	Rollback bool `protobuf:"varint,13,opt,name=rollback,proto3" json:"rollback,omitempty"`
+	// What a rebalance found when it listed the nodes.
+	Duplicates    int64     `protobuf:"varint,14,opt,name=duplicates,proto3" json:"duplicates,omitempty"`                  // chunks with more copies than replicas
+	Orphans       int64     `protobuf:"varint,15,opt,name=orphans,proto3" json:"orphans,omitempty"`                        // chunks of videos the metadata service doesn't know
+	OrphanKeys    []string  `protobuf:"bytes,16,rep,name=orphan_keys,json=orphanKeys,proto3" json:"orphan_keys,omitempty"` // the first orphans found
+	Throttle      *Throttle `protobuf:"bytes,17,opt,name=throttle,proto3" json:"throttle,omitempty"`                       // the job's own limits, the global ones apply on top
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_proto_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Job) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *Job) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetChunksScanned() int64 {
+	if x != nil {
+		return x.ChunksScanned
+	}
+	return 0
+}
+
+func (x *Job) GetChunksMoved() int64 {
+	if x != nil {
+		return x.ChunksMoved
+	}
+	return 0
+}
+
+func (x *Job) GetChunksFailed() int64 {
+	if x != nil {
+		return x.ChunksFailed
+	}
+	return 0
+}
+
+func (x *Job) GetBytesMoved() int64 {
+	if x != nil {
+		return x.BytesMoved
+	}
+	return 0
+}
+
+func (x *Job) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Job) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *Job) GetChunkErrors() []*ChunkError {
+	if x != nil {
+		return x.ChunkErrors
+	}
+	return nil
+}
+
+func (x *Job) GetRollback() bool {
+	if x != nil {
+		return x.Rollback
+	}
+	return false
+}
+
+func (x *Job) GetDuplicates() int64 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *Job) GetOrphans() int64 {
+	if x != nil {
+		return x.Orphans
+	}
+	return 0
+}
+
+func (x *Job) GetOrphanKeys() []string {
+	if x != nil {
+		return x.OrphanKeys
+	}
+	return nil
+}
+
+func (x *Job) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
+// Migration speed limits, zero means no limit.
+type Throttle struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	BytesPerSecond  int64                  `protobuf:"varint,1,opt,name=bytes_per_second,json=bytesPerSecond,proto3" json:"bytes_per_second,omitempty"`    // bytes written to new owners
+	ChunksPerSecond int64                  `protobuf:"varint,2,opt,name=chunks_per_second,json=chunksPerSecond,proto3" json:"chunks_per_second,omitempty"` // chunks moved
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Throttle) Reset() {
+	*x = Throttle{}
+	mi := &file_proto_admin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Throttle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Throttle) ProtoMessage() {}
+
+func (x *Throttle) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Throttle.ProtoReflect.Descriptor instead.
+func (*Throttle) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Throttle) GetBytesPerSecond() int64 {
+	if x != nil {
+		return x.BytesPerSecond
+	}
+	return 0
+}
+
+func (x *Throttle) GetChunksPerSecond() int64 {
+	if x != nil {
+		return x.ChunksPerSecond
+	}
+	return 0
+}
+
+type SetThrottleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"` // empty sets the global limits shared by all jobs
+	Throttle      *Throttle              `protobuf:"bytes,2,opt,name=throttle,proto3" json:"throttle,omitempty"`        // replaces both limits, unset removes them
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetThrottleRequest) Reset() {
+	*x = SetThrottleRequest{}
+	mi := &file_proto_admin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetThrottleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetThrottleRequest) ProtoMessage() {}
+
+func (x *SetThrottleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetThrottleRequest.ProtoReflect.Descriptor instead.
+func (*SetThrottleRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *SetThrottleRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *SetThrottleRequest) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
+type SetThrottleResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	GlobalThrottle *Throttle              `protobuf:"bytes,1,opt,name=global_throttle,json=globalThrottle,proto3" json:"global_throttle,omitempty"`
+	Job            *Job                   `protobuf:"bytes,2,opt,name=job,proto3" json:"job,omitempty"` // set when job_id was
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SetThrottleResponse) Reset() {
+	*x = SetThrottleResponse{}
+	mi := &file_proto_admin_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetThrottleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetThrottleResponse) ProtoMessage() {}
+
+func (x *SetThrottleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetThrottleResponse.ProtoReflect.Descriptor instead.
+func (*SetThrottleResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *SetThrottleResponse) GetGlobalThrottle() *Throttle {
+	if x != nil {
+		return x.GlobalThrottle
+	}
+	return nil
+}
+
+func (x *SetThrottleResponse) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+type ChunkError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkError) Reset() {
+	*x = ChunkError{}
+	mi := &file_proto_admin_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkError) ProtoMessage() {}
+
+func (x *ChunkError) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkError.ProtoReflect.Descriptor instead.
+func (*ChunkError) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ChunkError) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ChunkError) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_proto_admin_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type GetJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           *Job                   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobResponse) Reset() {
+	*x = GetJobResponse{}
+	mi := &file_proto_admin_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobResponse) ProtoMessage() {}
+
+func (x *GetJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobResponse.ProtoReflect.Descriptor instead.
+func (*GetJobResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *GetJobResponse) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+type ListJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_proto_admin_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{23}
+}
+
+type ListJobsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Jobs           []*Job                 `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`                                           // oldest first
+	GlobalThrottle *Throttle              `protobuf:"bytes,2,opt,name=global_throttle,json=globalThrottle,proto3" json:"global_throttle,omitempty"` // shared by all jobs
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_proto_admin_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ListJobsResponse) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+func (x *ListJobsResponse) GetGlobalThrottle() *Throttle {
+	if x != nil {
+		return x.GlobalThrottle
+	}
+	return nil
+}
+
+type CancelJobRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	JobId string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Abort instead: undo the job's membership change and return the chunks already moved to their old owners.
+	// Also works on a failed or canceled job.
+	Rollback      bool `protobuf:"varint,2,opt,name=rollback,proto3" json:"rollback,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobRequest) Reset() {
+	*x = CancelJobRequest{}
+	mi := &file_proto_admin_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobRequest) ProtoMessage() {}
+
+func (x *CancelJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobRequest.ProtoReflect.Descriptor instead.
+func (*CancelJobRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *CancelJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *CancelJobRequest) GetRollback() bool {
+	if x != nil {
+		return x.Rollback
+	}
+	return false
+}
+
+type CancelJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           *Job                   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"` // the job as it was when the cancel was sent, it stops after the chunk in flight
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobResponse) Reset() {
+	*x = CancelJobResponse{}
+	mi := &file_proto_admin_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobResponse) ProtoMessage() {}
+
+func (x *CancelJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobResponse.ProtoReflect.Descriptor instead.
+func (*CancelJobResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *CancelJobResponse) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+type ResumeJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeJobRequest) Reset() {
+	*x = ResumeJobRequest{}
+	mi := &file_proto_admin_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeJobRequest) ProtoMessage() {}
+
+func (x *ResumeJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeJobRequest.ProtoReflect.Descriptor instead.
+func (*ResumeJobRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ResumeJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type ResumeJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           *Job                   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeJobResponse) Reset() {
+	*x = ResumeJobResponse{}
+	mi := &file_proto_admin_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeJobResponse) ProtoMessage() {}
+
+func (x *ResumeJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeJobResponse.ProtoReflect.Descriptor instead.
+func (*ResumeJobResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ResumeJobResponse) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+type RebalanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PlanOnly      bool                   `protobuf:"varint,1,opt,name=plan_only,json=planOnly,proto3" json:"plan_only,omitempty"` // see AddNodeRequest.plan_only
+	Throttle      *Throttle              `protobuf:"bytes,2,opt,name=throttle,proto3" json:"throttle,omitempty"`                  // see AddNodeRequest.throttle
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RebalanceRequest) Reset() {
+	*x = RebalanceRequest{}
+	mi := &file_proto_admin_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RebalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RebalanceRequest) ProtoMessage() {}
+
+func (x *RebalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RebalanceRequest.ProtoReflect.Descriptor instead.
+func (*RebalanceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *RebalanceRequest) GetPlanOnly() bool {
+	if x != nil {
+		return x.PlanOnly
+	}
+	return false
+}
+
+func (x *RebalanceRequest) GetThrottle() *Throttle {
+	if x != nil {
+		return x.Throttle
+	}
+	return nil
+}
+
+type RebalanceResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	JobId string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Plan  *MigrationPlan         `protobuf:"bytes,2,opt,name=plan,proto3" json:"plan,omitempty"` // set for plan_only requests
+	// Also only for plan_only requests, a job reports these on the Job.
+	Duplicates    int64    `protobuf:"varint,3,opt,name=duplicates,proto3" json:"duplicates,omitempty"`
+	Orphans       int64    `protobuf:"varint,4,opt,name=orphans,proto3" json:"orphans,omitempty"`
+	OrphanKeys    []string `protobuf:"bytes,5,rep,name=orphan_keys,json=orphanKeys,proto3" json:"orphan_keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RebalanceResponse) Reset() {
+	*x = RebalanceResponse{}
+	mi := &file_proto_admin_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RebalanceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RebalanceResponse) ProtoMessage() {}
+
+func (x *RebalanceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RebalanceResponse.ProtoReflect.Descriptor instead.
+func (*RebalanceResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *RebalanceResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *RebalanceResponse) GetPlan() *MigrationPlan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+func (x *RebalanceResponse) GetDuplicates() int64 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *RebalanceResponse) GetOrphans() int64 {
+	if x != nil {
+		return x.Orphans
+	}
+	return 0
+}
+
+func (x *RebalanceResponse) GetOrphanKeys() []string {
+	if x != nil {
+		return x.OrphanKeys
+	}
+	return nil
+}
+
+var File_proto_admin_proto protoreflect.FileDescriptor
+
+const file_proto_admin_proto_rawDesc = "" +
+	"\n" +
+	"\x11proto/admin.proto\x12\n" +
+	"tritontube\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9a\x01\n" +
+	"\x0eAddNodeRequest\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12\x1b\n" +
+	"\tplan_only\x18\x03 \x01(\bR\bplanOnly\x120\n" +
+	"\bthrottle\x18\x04 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"\x87\x01\n" +
+	"\x0fAddNodeResponse\x12.\n" +
+	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\x12\x15\n" +
+	"\x06job_id\x18\x02 \x01(\tR\x05jobId\x12-\n" +
+	"\x04plan\x18\x03 \x01(\v2\x19.tritontube.MigrationPlanR\x04plan\"\x85\x01\n" +
+	"\x11RemoveNodeRequest\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x1b\n" +
+	"\tplan_only\x18\x02 \x01(\bR\bplanOnly\x120\n" +
+	"\bthrottle\x18\x03 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"\x8a\x01\n" +
+	"\x12RemoveNodeResponse\x12.\n" +
+	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\x12\x15\n" +
+	"\x06job_id\x18\x02 \x01(\tR\x05jobId\x12-\n" +
+	"\x04plan\x18\x03 \x01(\v2\x19.tritontube.MigrationPlanR\x04plan\"\x96\x01\n" +
+	"\rMigrationPlan\x12+\n" +
+	"\x05moves\x18\x01 \x03(\v2\x15.tritontube.ChunkMoveR\x05moves\x127\n" +
+	"\tdeletions\x18\x02 \x03(\v2\x19.tritontube.ChunkDeletionR\tdeletions\x12\x1f\n" +
+	"\vtotal_bytes\x18\x03 \x01(\x03R\n" +
+	"totalBytes\"m\n" +
+	"\tChunkMove\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12 \n" +
+	"\vdestination\x18\x03 \x01(\tR\vdestination\x12\x14\n" +
+	"\x05bytes\x18\x04 \x01(\x03R\x05bytes\"5\n" +
+	"\rChunkDeletion\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
+	"\x04node\x18\x02 \x01(\tR\x04node\"\x12\n" +
+	"\x10ListNodesRequest\"\\\n" +
+	"\x11ListNodesResponse\x12\x14\n" +
+	"\x05nodes\x18\x01 \x03(\tR\x05nodes\x121\n" +
+	"\tnode_info\x18\x02 \x03(\v2\x14.tritontube.NodeInfoR\bnodeInfo\"\xce\x01\n" +
+	"\bNodeInfo\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12%\n" +
+	"\x0ekeyspace_share\x18\x03 \x01(\x01R\rkeyspaceShare\x12\x16\n" +
+	"\x06health\x18\x04 \x01(\tR\x06health\x127\n" +
+	"\tlast_seen\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\x12\x18\n" +
+	"\adrained\x18\x06 \x01(\bR\adrained\"\x15\n" +
+	"\x13ClusterStatsRequest\"\x83\x01\n" +
+	"\x14ClusterStatsResponse\x12+\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x15.tritontube.NodeStatsR\x05nodes\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x02 \x01(\x03R\tfileCount\x12\x1f\n" +
+	"\vtotal_bytes\x18\x03 \x01(\x03R\n" +
+	"totalBytes\"\xe1\x02\n" +
+	"\tNodeStats\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12%\n" +
+	"\x0ekeyspace_share\x18\x03 \x01(\x01R\rkeyspaceShare\x12\x18\n" +
+	"\adrained\x18\x04 \x01(\bR\adrained\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x06 \x01(\x03R\tfileCount\x12\x1f\n" +
+	"\vtotal_bytes\x18\a \x01(\x03R\n" +
+	"totalBytes\x12\x1d\n" +
+	"\n" +
+	"byte_share\x18\b \x01(\x01R\tbyteShare\x12\x1d\n" +
+	"\n" +
+	"free_bytes\x18\t \x01(\x03R\tfreeBytes\x12\x1d\n" +
+	"\n" +
+	"disk_bytes\x18\n" +
+	" \x01(\x03R\tdiskBytes\x12.\n" +
+	"\x06videos\x18\v \x03(\v2\x16.tritontube.VideoStatsR\x06videos\"g\n" +
+	"\n" +
+	"VideoStats\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x02 \x01(\x03R\tfileCount\x12\x1f\n" +
+	"\vtotal_bytes\x18\x03 \x01(\x03R\n" +
+	"totalBytes\"\x81\x01\n" +
+	"\x10DrainNodeRequest\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x18\n" +
+	"\aundrain\x18\x02 \x01(\bR\aundrain\x120\n" +
+	"\bthrottle\x18\x03 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"*\n" +
+	"\x11DrainNodeResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\xda\x04\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12!\n" +
+	"\fnode_address\x18\x03 \x01(\tR\vnodeAddress\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12%\n" +
+	"\x0echunks_scanned\x18\x06 \x01(\x03R\rchunksScanned\x12!\n" +
+	"\fchunks_moved\x18\a \x01(\x03R\vchunksMoved\x12#\n" +
+	"\rchunks_failed\x18\b \x01(\x03R\fchunksFailed\x12\x1f\n" +
+	"\vbytes_moved\x18\t \x01(\x03R\n" +
+	"bytesMoved\x124\n" +
+	"\astarted\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x129\n" +
+	"\fchunk_errors\x18\f \x03(\v2\x16.tritontube.ChunkErrorR\vchunkErrors\x12\x1a\n" +
+	"\brollback\x18\r \x01(\bR\brollback\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x0e \x01(\x03R\n" +
+	"duplicates\x12\x18\n" +
+	"\aorphans\x18\x0f \x01(\x03R\aorphans\x12\x1f\n" +
+	"\vorphan_keys\x18\x10 \x03(\tR\n" +
+	"orphanKeys\x120\n" +
+	"\bthrottle\x18\x11 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"`\n" +
+	"\bThrottle\x12(\n" +
+	"\x10bytes_per_second\x18\x01 \x01(\x03R\x0ebytesPerSecond\x12*\n" +
+	"\x11chunks_per_second\x18\x02 \x01(\x03R\x0fchunksPerSecond\"]\n" +
+	"\x12SetThrottleRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x120\n" +
+	"\bthrottle\x18\x02 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"w\n" +
+	"\x13SetThrottleResponse\x12=\n" +
+	"\x0fglobal_throttle\x18\x01 \x01(\v2\x14.tritontube.ThrottleR\x0eglobalThrottle\x12!\n" +
+	"\x03job\x18\x02 \x01(\v2\x0f.tritontube.JobR\x03job\"4\n" +
+	"\n" +
+	"ChunkError\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"&\n" +
+	"\rGetJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"3\n" +
+	"\x0eGetJobResponse\x12!\n" +
+	"\x03job\x18\x01 \x01(\v2\x0f.tritontube.JobR\x03job\"\x11\n" +
+	"\x0fListJobsRequest\"v\n" +
+	"\x10ListJobsResponse\x12#\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x0f.tritontube.JobR\x04jobs\x12=\n" +
+	"\x0fglobal_throttle\x18\x02 \x01(\v2\x14.tritontube.ThrottleR\x0eglobalThrottle\"E\n" +
+	"\x10CancelJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1a\n" +
+	"\brollback\x18\x02 \x01(\bR\brollback\"6\n" +
+	"\x11CancelJobResponse\x12!\n" +
+	"\x03job\x18\x01 \x01(\v2\x0f.tritontube.JobR\x03job\")\n" +
+	"\x10ResumeJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"6\n" +
+	"\x11ResumeJobResponse\x12!\n" +
+	"\x03job\x18\x01 \x01(\v2\x0f.tritontube.JobR\x03job\"a\n" +
+	"\x10RebalanceRequest\x12\x1b\n" +
+	"\tplan_only\x18\x01 \x01(\bR\bplanOnly\x120\n" +
+	"\bthrottle\x18\x02 \x01(\v2\x14.tritontube.ThrottleR\bthrottle\"\xb4\x01\n" +
+	"\x11RebalanceResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12-\n" +
+	"\x04plan\x18\x02 \x01(\v2\x19.tritontube.MigrationPlanR\x04plan\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x03 \x01(\x03R\n" +
+	"duplicates\x12\x18\n" +
+	"\aorphans\x18\x04 \x01(\x03R\aorphans\x12\x1f\n" +
+	"\vorphan_keys\x18\x05 \x03(\tR\n" +
+	"orphanKeys2\xc8\x06\n" +
 	"\x18VideoContentAdminService\x12B\n" +
 	"\aAddNode\x12\x1a.tritontube.AddNodeRequest\x1a\x1b.tritontube.AddNodeResponse\x12K\n" +
 	"\n" +
 	"RemoveNode\x12\x1d.tritontube.RemoveNodeRequest\x1a\x1e.tritontube.RemoveNodeResponse\x12H\n" +
-	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponseB\x16Z\x14internal/proto;protob\x06proto3"
+	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponse\x12H\n" +
+	"\tDrainNode\x12\x1c.tritontube.DrainNodeRequest\x1a\x1d.tritontube.DrainNodeResponse\x12?\n" +
+	"\x06GetJob\x12\x19.tritontube.GetJobRequest\x1a\x1a.tritontube.GetJobResponse\x12E\n" +
+	"\bListJobs\x12\x1b.tritontube.ListJobsRequest\x1a\x1c.tritontube.ListJobsResponse\x12H\n" +
+	"\tCancelJob\x12\x1c.tritontube.CancelJobRequest\x1a\x1d.tritontube.CancelJobResponse\x12H\n" +
+	"\tResumeJob\x12\x1c.tritontube.ResumeJobRequest\x1a\x1d.tritontube.ResumeJobResponse\x12H\n" +
+	"\tRebalance\x12\x1c.tritontube.RebalanceRequest\x1a\x1d.tritontube.RebalanceResponse\x12Q\n" +
+	"\fClusterStats\x12\x1f.tritontube.ClusterStatsRequest\x1a .tritontube.ClusterStatsResponse\x12N\n" +
+	"\vSetThrottle\x12\x1e.tritontube.SetThrottleRequest\x1a\x1f.tritontube.SetThrottleResponseB\x16Z\x14internal/proto;protob\x06proto3"
 
 var (
 	file_proto_admin_proto_rawDescOnce sync.Once
@@ -312,27 +2062,94 @@ func file_proto_admin_proto_rawDescGZIP() []byte {
 	return file_proto_admin_proto_rawDescData
 }
 
-var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
 var file_proto_admin_proto_goTypes = []any{
-	(*AddNodeRequest)(nil),     // 0: tritontube.AddNodeRequest
-	(*AddNodeResponse)(nil),    // 1: tritontube.AddNodeResponse
-	(*RemoveNodeRequest)(nil),  // 2: tritontube.RemoveNodeRequest
-	(*RemoveNodeResponse)(nil), // 3: tritontube.RemoveNodeResponse
-	(*ListNodesRequest)(nil),   // 4: tritontube.ListNodesRequest
-	(*ListNodesResponse)(nil),  // 5: tritontube.ListNodesResponse
+	(*AddNodeRequest)(nil),        // 0: tritontube.AddNodeRequest
+	(*AddNodeResponse)(nil),       // 1: tritontube.AddNodeResponse
+	(*RemoveNodeRequest)(nil),     // 2: tritontube.RemoveNodeRequest
+	(*RemoveNodeResponse)(nil),    // 3: tritontube.RemoveNodeResponse
+	(*MigrationPlan)(nil),         // 4: tritontube.MigrationPlan
+	(*ChunkMove)(nil),             // 5: tritontube.ChunkMove
+	(*ChunkDeletion)(nil),         // 6: tritontube.ChunkDeletion
+	(*ListNodesRequest)(nil),      // 7: tritontube.ListNodesRequest
+	(*ListNodesResponse)(nil),     // 8: tritontube.ListNodesResponse
+	(*NodeInfo)(nil),              // 9: tritontube.NodeInfo
+	(*ClusterStatsRequest)(nil),   // 10: tritontube.ClusterStatsRequest
+	(*ClusterStatsResponse)(nil),  // 11: tritontube.ClusterStatsResponse
+	(*NodeStats)(nil),             // 12: tritontube.NodeStats
+	(*VideoStats)(nil),            // 13: tritontube.VideoStats
+	(*DrainNodeRequest)(nil),      // 14: tritontube.DrainNodeRequest
+	(*DrainNodeResponse)(nil),     // 15: tritontube.DrainNodeResponse
+	(*Job)(nil),                   // 16: tritontube.Job
+	(*Throttle)(nil),              // 17: tritontube.Throttle
+	(*SetThrottleRequest)(nil),    // 18: tritontube.SetThrottleRequest
+	(*SetThrottleResponse)(nil),   // 19: tritontube.SetThrottleResponse
+	(*ChunkError)(nil),            // 20: tritontube.ChunkError
+	(*GetJobRequest)(nil),         // 21: tritontube.GetJobRequest
+	(*GetJobResponse)(nil),        // 22: tritontube.GetJobResponse
+	(*ListJobsRequest)(nil),       // 23: tritontube.ListJobsRequest
+	(*ListJobsResponse)(nil),      // 24: tritontube.ListJobsResponse
+	(*CancelJobRequest)(nil),      // 25: tritontube.CancelJobRequest
+	(*CancelJobResponse)(nil),     // 26: tritontube.CancelJobResponse
+	(*ResumeJobRequest)(nil),      // 27: tritontube.ResumeJobRequest
+	(*ResumeJobResponse)(nil),     // 28: tritontube.ResumeJobResponse
+	(*RebalanceRequest)(nil),      // 29: tritontube.RebalanceRequest
+	(*RebalanceResponse)(nil),     // 30: tritontube.RebalanceResponse
+	(*timestamppb.Timestamp)(nil), // 31: google.protobuf.Timestamp
 }
 var file_proto_admin_proto_depIdxs = []int32{
-	0, // 0: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
-	2, // 1: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
-	4, // 2: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
-	1, // 3: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
-	3, // 4: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
-	5, // 5: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	17, // 0: tritontube.AddNodeRequest.throttle:type_name -> tritontube.Throttle
+	4,  // 1: tritontube.AddNodeResponse.plan:type_name -> tritontube.MigrationPlan
+	17, // 2: tritontube.RemoveNodeRequest.throttle:type_name -> tritontube.Throttle
+	4,  // 3: tritontube.RemoveNodeResponse.plan:type_name -> tritontube.MigrationPlan
+	5,  // 4: tritontube.MigrationPlan.moves:type_name -> tritontube.ChunkMove
+	6,  // 5: tritontube.MigrationPlan.deletions:type_name -> tritontube.ChunkDeletion
+	9,  // 6: tritontube.ListNodesResponse.node_info:type_name -> tritontube.NodeInfo
+	31, // 7: tritontube.NodeInfo.last_seen:type_name -> google.protobuf.Timestamp
+	12, // 8: tritontube.ClusterStatsResponse.nodes:type_name -> tritontube.NodeStats
+	13, // 9: tritontube.NodeStats.videos:type_name -> tritontube.VideoStats
+	17, // 10: tritontube.DrainNodeRequest.throttle:type_name -> tritontube.Throttle
+	31, // 11: tritontube.Job.started:type_name -> google.protobuf.Timestamp
+	31, // 12: tritontube.Job.finished:type_name -> google.protobuf.Timestamp
+	20, // 13: tritontube.Job.chunk_errors:type_name -> tritontube.ChunkError
+	17, // 14: tritontube.Job.throttle:type_name -> tritontube.Throttle
+	17, // 15: tritontube.SetThrottleRequest.throttle:type_name -> tritontube.Throttle
+	17, // 16: tritontube.SetThrottleResponse.global_throttle:type_name -> tritontube.Throttle
+	16, // 17: tritontube.SetThrottleResponse.job:type_name -> tritontube.Job
+	16, // 18: tritontube.GetJobResponse.job:type_name -> tritontube.Job
+	16, // 19: tritontube.ListJobsResponse.jobs:type_name -> tritontube.Job
+	17, // 20: tritontube.ListJobsResponse.global_throttle:type_name -> tritontube.Throttle
+	16, // 21: tritontube.CancelJobResponse.job:type_name -> tritontube.Job
+	16, // 22: tritontube.ResumeJobResponse.job:type_name -> tritontube.Job
+	17, // 23: tritontube.RebalanceRequest.throttle:type_name -> tritontube.Throttle
+	4,  // 24: tritontube.RebalanceResponse.plan:type_name -> tritontube.MigrationPlan
+	0,  // 25: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
+	2,  // 26: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
+	7,  // 27: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
+	14, // 28: tritontube.VideoContentAdminService.DrainNode:input_type -> tritontube.DrainNodeRequest
+	21, // 29: tritontube.VideoContentAdminService.GetJob:input_type -> tritontube.GetJobRequest
+	23, // 30: tritontube.VideoContentAdminService.ListJobs:input_type -> tritontube.ListJobsRequest
+	25, // 31: tritontube.VideoContentAdminService.CancelJob:input_type -> tritontube.CancelJobRequest
+	27, // 32: tritontube.VideoContentAdminService.ResumeJob:input_type -> tritontube.ResumeJobRequest
+	29, // 33: tritontube.VideoContentAdminService.Rebalance:input_type -> tritontube.RebalanceRequest
+	10, // 34: tritontube.VideoContentAdminService.ClusterStats:input_type -> tritontube.ClusterStatsRequest
+	18, // 35: tritontube.VideoContentAdminService.SetThrottle:input_type -> tritontube.SetThrottleRequest
+	1,  // 36: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
+	3,  // 37: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
+	8,  // 38: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
+	15, // 39: tritontube.VideoContentAdminService.DrainNode:output_type -> tritontube.DrainNodeResponse
+	22, // 40: tritontube.VideoContentAdminService.GetJob:output_type -> tritontube.GetJobResponse
+	24, // 41: tritontube.VideoContentAdminService.ListJobs:output_type -> tritontube.ListJobsResponse
+	26, // 42: tritontube.VideoContentAdminService.CancelJob:output_type -> tritontube.CancelJobResponse
+	28, // 43: tritontube.VideoContentAdminService.ResumeJob:output_type -> tritontube.ResumeJobResponse
+	30, // 44: tritontube.VideoContentAdminService.Rebalance:output_type -> tritontube.RebalanceResponse
+	11, // 45: tritontube.VideoContentAdminService.ClusterStats:output_type -> tritontube.ClusterStatsResponse
+	19, // 46: tritontube.VideoContentAdminService.SetThrottle:output_type -> tritontube.SetThrottleResponse
+	36, // [36:47] is the sub-list for method output_type
+	25, // [25:36] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
 }
 
 func init() { file_proto_admin_proto_init() }
@@ -346,7 +2163,7 @@ func file_proto_admin_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_admin_proto_rawDesc), len(file_proto_admin_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   31,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StorageService_UploadFile_FullMethodName   = "/storage.StorageService/UploadFile"
-	StorageService_DownloadFile_FullMethodName = "/storage.StorageService/DownloadFile"
-	StorageService_DeleteFile_FullMethodName   = "/storage.StorageService/DeleteFile"
-	StorageService_ListFiles_FullMethodName    = "/storage.StorageService/ListFiles"
+	StorageService_UploadFile_FullMethodName         = "/storage.StorageService/UploadFile"
+	StorageService_DownloadFile_FullMethodName       = "/storage.StorageService/DownloadFile"
+	StorageService_DeleteFile_FullMethodName         = "/storage.StorageService/DeleteFile"
+	StorageService_ListFiles_FullMethodName          = "/storage.StorageService/ListFiles"
+	StorageService_UploadFileStream_FullMethodName   = "/storage.StorageService/UploadFileStream"
+	StorageService_DownloadFileStream_FullMethodName = "/storage.StorageService/DownloadFileStream"
+	StorageService_ChecksumFile_FullMethodName       = "/storage.StorageService/ChecksumFile"
+	StorageService_ReadRange_FullMethodName          = "/storage.StorageService/ReadRange"
+	StorageService_GetStats_FullMethodName           = "/storage.StorageService/GetStats"
 )
 
 // StorageServiceClient is the client API for StorageService service.
@@ -34,10 +39,27 @@ type StorageServiceClient interface {
 	// Uploads a file to the storage service.
 	UploadFile(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadResponse, error)
 	// Downloads a file from the storage service.
+	// Fails with DATA_LOSS if the file no longer matches the SHA-256 recorded when it was written,
+	// and so does DownloadFileStream, after the last frame.
 	DownloadFile(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (*DownloadResponse, error)
 	// Deletes a file from the storage service.
 	DeleteFile(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
 	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
+	// Uploads a file as a stream of frames. The first frame carries the key.
+	// Use this instead of UploadFile for anything that may not fit in one gRPC message.
+	UploadFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadResponse], error)
+	// Downloads a file as a stream of frames, in order.
+	DownloadFileStream(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error)
+	// Returns the SHA-256 of a file, so a copy can be checked without downloading it.
+	ChecksumFile(ctx context.Context, in *ChecksumRequest, opts ...grpc.CallOption) (*ChecksumResponse, error)
+	// Reads one slice of a file, for HTTP range requests. At most 2 MiB come back per call,
+	// ask again from where the data ends for the rest. Only a range covering the whole file is
+	// checked against its SHA-256, a part can't be without reading all of it. Fails with
+	// DATA_LOSS for a file the node already found not to match its SHA-256 in a download
+	// or a whole file range, until it is uploaded again or deleted.
+	ReadRange(ctx context.Context, in *ReadRangeRequest, opts ...grpc.CallOption) (*ReadRangeResponse, error)
+	// Reports how much the node stores and how much disk it has left.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type storageServiceClient struct {
@@ -88,6 +110,68 @@ func (c *storageServiceClient) ListFiles(ctx context.Context, in *ListFilesReque
 	return out, nil
 }
 
+func (c *storageServiceClient) UploadFileStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[0], StorageService_UploadFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadChunk, UploadResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_UploadFileStreamClient = grpc.ClientStreamingClient[UploadChunk, UploadResponse]
+
+func (c *storageServiceClient) DownloadFileStream(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorageService_ServiceDesc.Streams[1], StorageService_DownloadFileStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, DownloadChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_DownloadFileStreamClient = grpc.ServerStreamingClient[DownloadChunk]
+
+func (c *storageServiceClient) ChecksumFile(ctx context.Context, in *ChecksumRequest, opts ...grpc.CallOption) (*ChecksumResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChecksumResponse)
+	err := c.cc.Invoke(ctx, StorageService_ChecksumFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) ReadRange(ctx context.Context, in *ReadRangeRequest, opts ...grpc.CallOption) (*ReadRangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReadRangeResponse)
+	err := c.cc.Invoke(ctx, StorageService_ReadRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, StorageService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorageServiceServer is the server API for StorageService service.
 // All implementations must embed UnimplementedStorageServiceServer
 // for forward compatibility.
@@ -97,10 +181,27 @@ type StorageServiceServer interface {
 	// Uploads a file to the storage service.
 	UploadFile(context.Context, *UploadRequest) (*UploadResponse, error)
 	// Downloads a file from the storage service.
+	// Fails with DATA_LOSS if the file no longer matches the SHA-256 recorded when it was written,
+	// and so does DownloadFileStream, after the last frame.
 	DownloadFile(context.Context, *DownloadRequest) (*DownloadResponse, error)
 	// Deletes a file from the storage service.
 	DeleteFile(context.Context, *DeleteRequest) (*DeleteResponse, error)
 	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
+	// Uploads a file as a stream of frames. The first frame carries the key.
+	// Use this instead of UploadFile for anything that may not fit in one gRPC message.
+	UploadFileStream(grpc.ClientStreamingServer[UploadChunk, UploadResponse]) error
+	// Downloads a file as a stream of frames, in order.
+	DownloadFileStream(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error
+	// Returns the SHA-256 of a file, so a copy can be checked without downloading it.
+	ChecksumFile(context.Context, *ChecksumRequest) (*ChecksumResponse, error)
+	// Reads one slice of a file, for HTTP range requests. At most 2 MiB come back per call,
+	// ask again from where the data ends for the rest. Only a range covering the whole file is
+	// checked against its SHA-256, a part can't be without reading all of it. Fails with
+	// DATA_LOSS for a file the node already found not to match its SHA-256 in a download
+	// or a whole file range, until it is uploaded again or deleted.
+	ReadRange(context.Context, *ReadRangeRequest) (*ReadRangeResponse, error)
+	// Reports how much the node stores and how much disk it has left.
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedStorageServiceServer()
 }
 
@@ -123,6 +224,21 @@ func (UnimplementedStorageServiceServer) DeleteFile(context.Context, *DeleteRequ
 func (UnimplementedStorageServiceServer) ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
 }
+func (UnimplementedStorageServiceServer) UploadFileStream(grpc.ClientStreamingServer[UploadChunk, UploadResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadFileStream not implemented")
+}
+func (UnimplementedStorageServiceServer) DownloadFileStream(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadFileStream not implemented")
+}
+func (UnimplementedStorageServiceServer) ChecksumFile(context.Context, *ChecksumRequest) (*ChecksumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChecksumFile not implemented")
+}
+func (UnimplementedStorageServiceServer) ReadRange(context.Context, *ReadRangeRequest) (*ReadRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadRange not implemented")
+}
+func (UnimplementedStorageServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedStorageServiceServer) mustEmbedUnimplementedStorageServiceServer() {}
 func (UnimplementedStorageServiceServer) testEmbeddedByValue()                        {}
 
@@ -216,6 +332,78 @@ func _StorageService_ListFiles_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorageService_UploadFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServiceServer).UploadFileStream(&grpc.GenericServerStream[UploadChunk, UploadResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_UploadFileStreamServer = grpc.ClientStreamingServer[UploadChunk, UploadResponse]
+
+func _StorageService_DownloadFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServiceServer).DownloadFileStream(m, &grpc.GenericServerStream[DownloadRequest, DownloadChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorageService_DownloadFileStreamServer = grpc.ServerStreamingServer[DownloadChunk]
+
+func _StorageService_ChecksumFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChecksumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ChecksumFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ChecksumFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ChecksumFile(ctx, req.(*ChecksumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_ReadRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).ReadRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_ReadRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).ReadRange(ctx, req.(*ReadRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StorageService_ServiceDesc is the grpc.ServiceDesc for StorageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -239,7 +427,30 @@ var StorageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListFiles",
 			Handler:    _StorageService_ListFiles_Handler,
 		},
+		{
+			MethodName: "ChecksumFile",
+			Handler:    _StorageService_ChecksumFile_Handler,
+		},
+		{
+			MethodName: "ReadRange",
+			Handler:    _StorageService_ReadRange_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _StorageService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadFileStream",
+			Handler:       _StorageService_UploadFileStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadFileStream",
+			Handler:       _StorageService_DownloadFileStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "storage.proto",
 }
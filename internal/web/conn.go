@@ -0,0 +1,96 @@
+package web
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	storagepb "tritontube/internal/proto/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// DialSettings control how the content service connects to one storage node.
+// Set them for every node in the nw options, or per node after the address,
+// e.g. "host:port@2;dial_timeout=2s;keepalive=1m".
+type DialSettings struct {
+	DialTimeout      time.Duration // how long one connection attempt may take
+	BackoffMax       time.Duration // cap on the exponential backoff between reconnect attempts
+	Keepalive        time.Duration // ping after this long without traffic, 0 turns pings off
+	KeepaliveTimeout time.Duration // drop the connection if a ping goes unanswered this long
+}
+
+// DefaultDialSettings apply to every node unless the nw options say otherwise.
+var DefaultDialSettings = DialSettings{
+	DialTimeout:      5 * time.Second,
+	BackoffMax:       30 * time.Second,
+	Keepalive:        30 * time.Second,
+	KeepaliveTimeout: 10 * time.Second,
+}
+
+// nodeConn is the connection to one storage node. Nothing is dialed until the first call needs
+// it, and after that gRPC reconnects on its own with exponential backoff whenever it drops.
+// A node that can't be reached only fails the calls made to it.
+type nodeConn struct {
+	addr     string
+	settings DialSettings
+
+	mu     sync.Mutex
+	cc     *grpc.ClientConn
+	client storagepb.StorageServiceClient
+	closed bool
+}
+
+func newNodeConn(addr string, settings DialSettings) *nodeConn {
+	return &nodeConn{addr: addr, settings: settings}
+}
+
+// get returns the connection and stub, creating them on first use.
+func (c *nodeConn) get() (*grpc.ClientConn, storagepb.StorageServiceClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, nil, fmt.Errorf("connection to node %s is closed", c.addr)
+	}
+	if c.cc != nil {
+		return c.cc, c.client, nil
+	}
+
+	bo := backoff.DefaultConfig
+	bo.MaxDelay = c.settings.BackoffMax
+	opts := []grpc.DialOption{
+		//no need for secure connection in this example
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           bo,
+			MinConnectTimeout: c.settings.DialTimeout,
+		}),
+	}
+	if c.settings.Keepalive > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    c.settings.Keepalive,
+			Timeout: c.settings.KeepaliveTimeout,
+		}))
+	}
+	clientConn, err := grpc.NewClient(c.addr, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial node %s: %w", c.addr, err)
+	}
+	// give grpc the conn, get the stub where we can call gRPC methods on that server
+	c.cc = clientConn
+	c.client = storagepb.NewStorageServiceClient(clientConn)
+	return c.cc, c.client, nil
+}
+
+// close shuts the connection down for good, later calls to get fail.
+func (c *nodeConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.cc != nil {
+		c.cc.Close() // actually close the network connection
+		c.cc, c.client = nil, nil
+	}
+}
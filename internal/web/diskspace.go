@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package web
+
+import "syscall"
+
+// DiskSpace returns the bytes still free for the server and the size of the
+// filesystem holding baseDir.
+func (s *FSVideoContentService) DiskSpace() (free, total int64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.baseDir, &st); err != nil {
+		return 0, 0, err
+	}
+	// Bavail leaves out the blocks reserved for root
+	return int64(st.Bavail) * int64(st.Bsize), int64(st.Blocks) * int64(st.Bsize), nil
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package web
+
+import "errors"
+
+// DiskSpace isn't implemented here, stats report the disk space as unknown.
+func (s *FSVideoContentService) DiskSpace() (free, total int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -4,6 +4,9 @@ package web
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
 	"sort"
 	"strings"
 	"time"
@@ -101,3 +104,153 @@ func (s *EtcdVideoMetadataService) List() ([]VideoMetadata, error) {
 	})
 	return vids, nil
 }
+
+// Compile-time assertion that EtcdVideoMetadataService implements MembershipStore, MembershipWatcher and MigrationJournal
+var _ MembershipStore = (*EtcdVideoMetadataService)(nil)
+var _ MembershipWatcher = (*EtcdVideoMetadataService)(nil)
+var _ MigrationJournal = (*EtcdVideoMetadataService)(nil)
+
+// membershipKey holds the ring membership as a JSON list, outside the "videos/" prefix.
+const membershipKey = "ring/members"
+
+func (s *EtcdVideoMetadataService) LoadMembership() ([]Member, bool, error) {
+	members, _, found, err := s.LoadMembershipRev()
+	return members, found, err
+}
+
+func (s *EtcdVideoMetadataService) LoadMembershipRev() ([]Member, int64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a timeout for the operation
+	defer cancel()
+	resp, err := s.client.Get(ctx, membershipKey)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(resp.Kvs) == 0 { // nothing saved yet
+		return nil, 0, false, nil
+	}
+	var members []Member
+	if err := json.Unmarshal(resp.Kvs[0].Value, &members); err != nil {
+		return nil, 0, false, fmt.Errorf("decode %s: %w", membershipKey, err)
+	}
+	return members, resp.Kvs[0].ModRevision, true, nil
+}
+
+// SaveMembership only seeds the membership when nothing is stored yet. Changes have to say
+// which revision they were based on, see SaveMembershipIf.
+func (s *EtcdVideoMetadataService) SaveMembership(members []Member) error {
+	_, err := s.SaveMembershipIf(members, 0)
+	return err
+}
+
+// SaveMembershipIf writes members only if the membership key's mod revision is still rev,
+// rev 0 meaning the key doesn't exist yet.
+func (s *EtcdVideoMetadataService) SaveMembershipIf(members []Member, rev int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a timeout for the operation
+	defer cancel()
+	val, err := json.Marshal(members)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(membershipKey), "=", rev)).
+		Then(clientv3.OpPut(membershipKey, string(val))).
+		Commit()
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Succeeded {
+		return 0, ErrMembershipConflict
+	}
+	return resp.Header.Revision, nil
+}
+
+// WatchMembership follows the membership key. Each round reads the current value and then
+// watches from the revision after it, so nothing saved in between is missed, even after
+// the watch breaks (e.g. the revision was compacted) and has to start over.
+func (s *EtcdVideoMetadataService) WatchMembership(ctx context.Context) <-chan MembershipUpdate {
+	out := make(chan MembershipUpdate)
+	send := func(raw []byte, rev int64) bool {
+		var members []Member
+		if err := json.Unmarshal(raw, &members); err != nil {
+			log.Printf("decode %s: %v", membershipKey, err)
+			return true
+		}
+		select {
+		case out <- MembershipUpdate{Members: members, Rev: rev}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(out)
+		for ctx.Err() == nil {
+			getCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			resp, err := s.client.Get(getCtx, membershipKey)
+			cancel()
+			if err != nil {
+				log.Printf("etcd get %s: %v", membershipKey, err)
+				time.Sleep(time.Second)
+				continue
+			}
+			if len(resp.Kvs) > 0 && !send(resp.Kvs[0].Value, resp.Kvs[0].ModRevision) {
+				return
+			}
+			for wresp := range s.client.Watch(ctx, membershipKey, clientv3.WithRev(resp.Header.Revision+1)) {
+				if err := wresp.Err(); err != nil {
+					log.Printf("etcd watch on %s: %v", membershipKey, err)
+					break
+				}
+				for _, ev := range wresp.Events {
+					if ev.Type == clientv3.EventTypePut && !send(ev.Kv.Value, ev.Kv.ModRevision) {
+						return
+					}
+				}
+			}
+			time.Sleep(time.Second)
+		}
+	}()
+	return out
+}
+
+// migrationPrefix holds one JSON MigrationRecord per unfinished migration job.
+const migrationPrefix = "migrations/"
+
+func (s *EtcdVideoMetadataService) SaveMigration(rec MigrationRecord) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a timeout for the operation
+	defer cancel()
+	val, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(ctx, migrationPrefix+rec.ID, string(val))
+	return err
+}
+
+func (s *EtcdVideoMetadataService) LoadMigrations() ([]MigrationRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a timeout for the operation
+	defer cancel()
+	resp, err := s.client.Get(ctx, migrationPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var recs []MigrationRecord
+	for _, kv := range resp.Kvs {
+		var rec MigrationRecord
+		if err := json.Unmarshal(kv.Value, &rec); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", kv.Key, err)
+		}
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Started.Before(recs[j].Started)
+	})
+	return recs, nil
+}
+
+func (s *EtcdVideoMetadataService) DeleteMigration(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a timeout for the operation
+	defer cancel()
+	_, err := s.client.Delete(ctx, migrationPrefix+id)
+	return err
+}
@@ -5,15 +5,90 @@
 package web
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// checksumSuffix names the sidecar next to every file that holds the SHA-256 it was written with.
+// ListAll leaves them out.
+const checksumSuffix = ".sha256"
+
+// ErrCorrupt means the content doesn't match its SHA-256: a stored file changed since it was
+// written, or an upload doesn't match the digest sent with it.
+var ErrCorrupt = errors.New("content doesn't match its SHA-256")
+
+// tempMarker is in the name of every file still being written, ".<name>.tmp-<random>".
+// ListAll leaves them out and NewFSVideoContentService deletes the ones a crash left behind.
+const tempMarker = ".tmp-"
+
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempMarker)
+}
+
+// Durability says how hard a write works to survive a crash or power loss. Every level
+// writes to a temp file and renames it into place, so readers never see half a file.
+type Durability int
+
+const (
+	DurabilityNone Durability = iota // leave flushing to the OS
+	DurabilityFile                   // fsync the file before the rename
+	DurabilityFull                   // also fsync the directory, so the rename itself is on disk
+)
+
+// DefaultDurability is what NewFSVideoContentService uses.
+const DefaultDurability = DurabilityFile
+
+// ParseDurability reads "none", "file" or "full".
+func ParseDurability(s string) (Durability, error) {
+	switch s {
+	case "none":
+		return DurabilityNone, nil
+	case "file":
+		return DurabilityFile, nil
+	case "full":
+		return DurabilityFull, nil
+	}
+	return 0, fmt.Errorf("durability must be none, file or full, got %q", s)
+}
+
+// FSOptions tunes an FSVideoContentService.
+type FSOptions struct {
+	Durability Durability
+	Quota      int64 // most bytes of files kept under baseDir, 0 for no limit
+	Reserve    int64 // free disk space writes have to leave, 0 for none
+}
+
 // FSVideoContentService implements VideoContentService using the local filesystem.
 type FSVideoContentService struct {
 	baseDir string
+	root    string // baseDir with its symlinks resolved, nothing may lead outside it
+	opts    FSOptions
+	used    atomic.Int64 // bytes of files under baseDir, only kept with a quota
+	pending atomic.Int64 // bytes of writes still in progress, they all share the room above the reserve
+
+	// a file and its checksum sidecar are replaced, read and deleted together under the
+	// lock for their path, so nobody pairs the old content with the new digest or the other way round
+	locks [64]sync.RWMutex
+
+	corruptMu sync.Mutex
+	corrupt   map[string]bool // paths found not to match their checksum since the service started
+}
+
+// pathLock returns the lock for a file, paths share them by hash.
+func (s *FSVideoContentService) pathLock(path string) *sync.RWMutex {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return &s.locks[h.Sum32()%uint32(len(s.locks))]
 }
 
 //video content service interface signatures
@@ -25,57 +100,385 @@ var _ VideoContentService = (*FSVideoContentService)(nil)
 
 // NewFSVideoContentService returns a filesystem-based content service rooted at baseDir.
 func NewFSVideoContentService(baseDir string) *FSVideoContentService {
+	return NewFSVideoContentServiceWith(baseDir, FSOptions{Durability: DefaultDurability})
+}
+
+// NewFSVideoContentServiceWith is NewFSVideoContentService with options.
+// Temp files left under baseDir by writes a crash cut short are deleted.
+func NewFSVideoContentServiceWith(baseDir string, opts FSOptions) *FSVideoContentService {
 	// create the base directory if it doesn't exist
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		log.Print(err)
 	}
-	return &FSVideoContentService{baseDir: baseDir}
+	s := &FSVideoContentService{baseDir: baseDir, root: baseDir, opts: opts, corrupt: make(map[string]bool)}
+	if root, err := filepath.EvalSymlinks(baseDir); err == nil {
+		s.root = root
+	} else {
+		log.Print(err)
+	}
+	s.removeTempFiles()
+	if opts.Quota > 0 {
+		s.countUsage()
+	}
+	return s
+}
+
+// removeTempFiles deletes what unfinished writes left behind, nothing else is writing yet.
+func (s *FSVideoContentService) removeTempFiles() {
+	removed := 0
+	err := filepath.Walk(s.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isTempFile(info.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to clean up temp files under %s: %v", s.baseDir, err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d temp files left under %s by unfinished writes", removed, s.baseDir)
+	}
 }
 
 // Write stores the given data under baseDir/videoId/filename.
 func (s *FSVideoContentService) Write(videoId, filename string, data []byte) error {
+	return s.WriteFrom(videoId, filename, bytes.NewReader(data), nil)
+}
 
-	dir := filepath.Join(s.baseDir, videoId)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+// WriteFrom stores everything read from r under baseDir/videoId/filename without buffering it all in memory,
+// and records its SHA-256 in the sidecar. If sum is set and the content doesn't match it, nothing is kept
+// and the error is ErrCorrupt. The content goes to a temp file that is renamed into place once complete,
+// so a failed copy leaves the old file as it was.
+func (s *FSVideoContentService) WriteFrom(videoId, filename string, r io.Reader, sum []byte) error {
+	// If your filename ever contains sub-folders
+	// (e.g. "segments/chunk-00001.m4s"), ensure *that* path exists too
+	fullPath, err := s.fullPath(videoId, filename)
+	if err != nil {
 		return err
 	}
-
-	// 2) If your filename ever contains sub-folders
-	//    (e.g. "segments/chunk-00001.m4s"), ensure *that* path exists too:
-	fullPath := filepath.Join(dir, filename)
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
 		return err
 	}
+	q, err := s.newQuotaReader(r)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			q.release()
+		}
+		q.done()
+	}()
+
+	h := sha256.New()
+	tmp, err := s.writeTemp(fullPath, io.TeeReader(q, h))
+	if err != nil {
+		return err
+	}
+	got := h.Sum(nil)
+	if sum != nil && !bytes.Equal(got, sum) {
+		os.Remove(tmp)
+		return fmt.Errorf("%w: got %x, want %x", ErrCorrupt, got, sum)
+	}
+	// once renamed the content is in, even if its checksum couldn't be written
+	if committed, err = s.commit(fullPath, tmp, got); err != nil {
+		return err
+	}
+	return s.syncDir(filepath.Dir(fullPath))
+}
+
+// commit renames the finished temp file into place and writes its checksum next to it,
+// holding the path's lock so readers see either the old pair or the new one.
+// It reports whether the new content made it into place.
+func (s *FSVideoContentService) commit(fullPath, tmp string, sum []byte) (bool, error) {
+	lock := s.pathLock(fullPath)
+	lock.Lock()
+	defer lock.Unlock()
+	// the old checksum goes first, a crash before the new one is in must not leave it next to new content
+	if err := os.Remove(fullPath + checksumSuffix); err != nil && !os.IsNotExist(err) {
+		os.Remove(tmp)
+		return false, err
+	}
+	var oldSize int64 // an overwrite frees the old file's bytes
+	if info, err := os.Lstat(fullPath); err == nil {
+		oldSize = info.Size()
+	}
+	if err := os.Rename(tmp, fullPath); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	s.used.Add(-oldSize)
+	s.setCorrupt(fullPath, false)
+
+	sumTmp, err := s.writeTemp(fullPath+checksumSuffix, bytes.NewReader(sum))
+	if err != nil {
+		return true, err
+	}
+	if err := os.Rename(sumTmp, fullPath+checksumSuffix); err != nil {
+		os.Remove(sumTmp)
+		return true, err
+	}
+	return true, nil
+}
+
+// writeTemp copies r into a new temp file in the directory of path and returns its name.
+// The file is on disk already if the durability level asks for it.
+func (s *FSVideoContentService) writeTemp(path string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+tempMarker+"*")
+	if err != nil {
+		return "", err
+	}
+	err = f.Chmod(0644) // CreateTemp makes it 0600
+	if err == nil {
+		_, err = io.Copy(f, r)
+	}
+	if err == nil && s.opts.Durability >= DurabilityFile {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDir flushes the renames in dir with DurabilityFull, a no-op otherwise.
+func (s *FSVideoContentService) syncDir(dir string) error {
+	if s.opts.Durability < DurabilityFull {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Checksum returns the SHA-256 recorded when the file was written, nil for files
+// written before checksums were kept.
+func (s *FSVideoContentService) Checksum(videoId, filename string) ([]byte, error) {
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	lock := s.pathLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+	return readChecksum(path)
+}
 
-	return os.WriteFile(fullPath, data, 0644)
+// readChecksum reads the sidecar of path, the caller holds the path's lock.
+func readChecksum(path string) ([]byte, error) {
+	sum, err := os.ReadFile(path + checksumSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return sum, err
+}
+
+// Open returns the file for videoId/filename so callers can stream it. The caller closes it.
+func (s *FSVideoContentService) Open(videoId, filename string) (*os.File, error) {
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// OpenChecked is Open plus the checksum recorded for the content of the opened file. The pair
+// is read under the path's lock, so a write landing while the caller streams the file can't
+// leave it checking the old content against the new digest. The caller closes the file.
+func (s *FSVideoContentService) OpenChecked(videoId, filename string) (*os.File, []byte, error) {
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	lock := s.pathLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	sum, err := readChecksum(path)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, sum, nil
 }
 
 // Read retrieves the content data for the specified video and filename.
+// Content that doesn't match its recorded checksum is an ErrCorrupt.
 func (s *FSVideoContentService) Read(videoId, filename string) ([]byte, error) {
-	path := filepath.Join(s.baseDir, videoId, filename)
-	return os.ReadFile(path)
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	lock := s.pathLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(path, data); err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", videoId, filename, err)
+	}
+	return data, nil
+}
+
+// verify checks data, all of the file at path, against its checksum and remembers a mismatch.
+// The caller holds the path's lock.
+func (s *FSVideoContentService) verify(path string, data []byte) error {
+	sum, err := readChecksum(path)
+	if err != nil {
+		return err
+	}
+	if got := sha256.Sum256(data); sum != nil && !bytes.Equal(got[:], sum) {
+		s.setCorrupt(path, true)
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// setCorrupt marks path as not matching its checksum, or clears the mark once it is rewritten
+// or deleted. The caller holds the path's lock, for writing to clear it.
+func (s *FSVideoContentService) setCorrupt(path string, corrupt bool) {
+	s.corruptMu.Lock()
+	defer s.corruptMu.Unlock()
+	if corrupt {
+		s.corrupt[path] = true
+	} else {
+		delete(s.corrupt, path)
+	}
+}
+
+func (s *FSVideoContentService) knownCorrupt(path string) bool {
+	s.corruptMu.Lock()
+	defer s.corruptMu.Unlock()
+	return s.corrupt[path]
+}
+
+// MarkCorrupt records that the content checksummed as sum didn't match it, for callers that
+// verify a file themselves while streaming it. Nothing is marked if the file was rewritten
+// since, sum then no longer is its checksum.
+func (s *FSVideoContentService) MarkCorrupt(videoId, filename string, sum []byte) {
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return
+	}
+	lock := s.pathLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+	if current, err := readChecksum(path); err == nil && current != nil && bytes.Equal(current, sum) {
+		s.setCorrupt(path, true)
+	}
+}
+
+// ReadRange seeks to the range and reads only that, see RangeReader. Checking a part against
+// the file's checksum would take reading all of it, so only a range covering the whole file is
+// verified. Ranges of a file already found corrupt, by Read or a download, are an ErrCorrupt.
+func (s *FSVideoContentService) ReadRange(videoId, filename string, offset, length int64) ([]byte, int64, error) {
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	lock := s.pathLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+	if s.knownCorrupt(path) {
+		return nil, 0, fmt.Errorf("%s/%s: %w", videoId, filename, ErrCorrupt)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+	start, n := clampRange(size, offset, length)
+	if n == 0 {
+		return nil, size, nil
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, 0, err
+	}
+	if n == size {
+		if err := s.verify(path, data); err != nil {
+			return nil, 0, fmt.Errorf("%s/%s: %w", videoId, filename, err)
+		}
+	}
+	return data, size, nil
 }
 
 func (s *FSVideoContentService) Delete(videoId, filename string) error {
-	path := filepath.Join(s.baseDir, videoId, filename)
+	path, err := s.fullPath(videoId, filename)
+	if err != nil {
+		return err
+	}
+	lock := s.pathLock(path)
+	lock.Lock()
+	defer lock.Unlock()
+	s.setCorrupt(path, false)
+	if err := os.Remove(path + checksumSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var size int64 // given back to the quota
+	if info, err := os.Lstat(path); err == nil {
+		size = info.Size()
+	}
 	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
 			return nil // File does not exist, return nil
 		}
 		return err // Return any other error
 	}
+	s.used.Add(-size)
 	return nil // Successfully deleted
 }
 
 func (s *FSVideoContentService) ListAll() ([]string, error) {
+	keys, _, err := s.ListAllSizes()
+	return keys, err
+}
+
+// ListAllSizes is ListAll with the size of each file, in the same order.
+func (s *FSVideoContentService) ListAllSizes() ([]string, []int64, error) {
 	var keys []string
+	var sizes []int64
 	err := filepath.Walk(s.baseDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if info.IsDir() || strings.HasSuffix(path, checksumSuffix) || isTempFile(info.Name()) {
 			return nil
 		}
+		if !info.Mode().IsRegular() {
+			return nil // e.g. a symlink, the service never makes one and won't read through it
+		}
 		// Compute the path relative to baseDir
 		rel, err := filepath.Rel(s.baseDir, path)
 		if err != nil {
@@ -84,10 +487,11 @@ func (s *FSVideoContentService) ListAll() ([]string, error) {
 		// Normalize to forward slashes
 		key := filepath.ToSlash(rel)
 		keys = append(keys, key)
+		sizes = append(sizes, info.Size())
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("ListFiles walk error: %v", err)
+		return nil, nil, fmt.Errorf("ListFiles walk error: %v", err)
 	}
-	return keys, nil
+	return keys, sizes, nil
 }
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidPath is returned for a videoId or filename that could reach outside baseDir,
+// or that would clash with the service's own checksum and temp files.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ValidateKey checks a videoId and filename before they go anywhere near the filesystem.
+// videoId is a single name, filename may have sub-folders separated by "/". Both must be
+// canonical relative paths: no leading "/", no empty, "." or ".." segments, no backslashes
+// and no NUL bytes.
+func ValidateKey(videoId, filename string) error {
+	if strings.Contains(videoId, "/") {
+		return fmt.Errorf("%w: video id %q contains a slash", ErrInvalidPath, videoId)
+	}
+	if err := validateRelPath(videoId); err != nil {
+		return fmt.Errorf("%w: video id %q %v", ErrInvalidPath, videoId, err)
+	}
+	if err := validateRelPath(filename); err != nil {
+		return fmt.Errorf("%w: filename %q %v", ErrInvalidPath, filename, err)
+	}
+	if base := path.Base(filename); strings.HasSuffix(base, checksumSuffix) || isTempFile(base) {
+		return fmt.Errorf("%w: filename %q is reserved for the storage service", ErrInvalidPath, filename)
+	}
+	return nil
+}
+
+func validateRelPath(p string) error {
+	switch {
+	case p == "":
+		return errors.New("is empty")
+	case strings.ContainsRune(p, 0):
+		return errors.New("contains a NUL byte")
+	case strings.Contains(p, `\`):
+		return errors.New("contains a backslash") // a separator on Windows
+	case strings.HasPrefix(p, "/"):
+		return errors.New("is absolute")
+	}
+	for _, seg := range strings.Split(p, "/") {
+		switch seg {
+		case "":
+			return errors.New("has an empty segment")
+		case ".", "..":
+			return fmt.Errorf("has a %q segment", seg)
+		}
+	}
+	// catches volume names and reserved names on Windows
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return errors.New("is not a local path")
+	}
+	return nil
+}
+
+// fullPath validates the key and joins it onto baseDir, making sure no symlink on the way
+// leads out of baseDir.
+func (s *FSVideoContentService) fullPath(videoId, filename string) (string, error) {
+	if err := ValidateKey(videoId, filename); err != nil {
+		return "", err
+	}
+	p := filepath.Join(s.baseDir, videoId, filepath.FromSlash(filename))
+	if err := s.checkInside(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// checkInside resolves the symlinks in the deepest part of p that exists and fails unless it
+// ends up under baseDir. Whatever doesn't exist yet can't be a link.
+func (s *FSVideoContentService) checkInside(p string) error {
+	for {
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			p = filepath.Dir(p)
+			continue
+		}
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			// a dangling link, nothing tells where it would lead once its target exists
+			return fmt.Errorf("%w: %s: %v", ErrInvalidPath, p, err)
+		}
+		rel, err := filepath.Rel(s.root, real)
+		if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+			return fmt.Errorf("%w: %s leads outside the storage root", ErrInvalidPath, p)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		videoId  string
+		filename string
+		ok       bool
+	}{
+		{"plain", "v1", "manifest.mpd", true},
+		{"sub-folder", "v1", "segments/chunk-00001.m4s", true},
+		{"dots in names", "v1.2", "init..m4s", true},
+		{"parent in filename", "v1", "../../etc/x", false},
+		{"parent as video id", "..", "x", false},
+		{"parent inside filename", "v1", "a/../../x", false},
+		{"absolute filename", "v1", "/abs", false},
+		{"absolute video id", "/abs", "x", false},
+		{"slash in video id", "a/b", "x", false},
+		{"dot segment", "v1", "a/./b", false},
+		{"dot video id", ".", "x", false},
+		{"empty segment", "v1", "a//b", false},
+		{"trailing slash", "v1", "a/", false},
+		{"empty video id", "", "x", false},
+		{"empty filename", "v1", "", false},
+		{"NUL in filename", "v1", "a\x00b", false},
+		{"NUL in video id", "v\x001", "x", false},
+		{"backslash in filename", "v1", `..\..\x`, false},
+		{"backslash in video id", `v\1`, "x", false},
+		{"checksum sidecar", "v1", "chunk.m4s" + checksumSuffix, false},
+		{"checksum sidecar in sub-folder", "v1", "seg/chunk.m4s" + checksumSuffix, false},
+		{"temp file", "v1", ".chunk.m4s" + tempMarker + "123", false},
+		{"temp file in sub-folder", "v1", "seg/.chunk.m4s" + tempMarker + "123", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKey(tt.videoId, tt.filename)
+			if tt.ok && err != nil {
+				t.Fatalf("ValidateKey(%q, %q) = %v, want nil", tt.videoId, tt.filename, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidPath) {
+				t.Fatalf("ValidateKey(%q, %q) = %v, want ErrInvalidPath", tt.videoId, tt.filename, err)
+			}
+		})
+	}
+}
+
+func TestFullPath(t *testing.T) {
+	dir := t.TempDir()
+	baseDir := filepath.Join(dir, "base")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(baseDir, "v1", "seg"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		filepath.Join(baseDir, "escape"):             outside,                               // a video id leading out
+		filepath.Join(baseDir, "v1", "secret"):       filepath.Join(outside, "secret"),      // a file leading out
+		filepath.Join(baseDir, "v1", "up"):           "../../outside",                       // relative, leading out
+		filepath.Join(baseDir, "v1", "dangling"):     filepath.Join(outside, "not-yet"),     // nothing there yet
+		filepath.Join(baseDir, "alias"):              filepath.Join(baseDir, "v1"),          // stays inside
+		filepath.Join(baseDir, "v1", "seg", "local"): filepath.Join(baseDir, "v1", "other"), // dangling but inside
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("can't create symlinks here: %v", err)
+		}
+	}
+	s := NewFSVideoContentService(baseDir)
+
+	tests := []struct {
+		name     string
+		videoId  string
+		filename string
+		want     string // "" if the key must be refused
+	}{
+		{"new file", "v1", "chunk.m4s", filepath.Join(baseDir, "v1", "chunk.m4s")},
+		{"new video", "v2", "seg/chunk.m4s", filepath.Join(baseDir, "v2", "seg", "chunk.m4s")},
+		{"link staying inside", "alias", "chunk.m4s", filepath.Join(baseDir, "alias", "chunk.m4s")},
+		{"parent segments", "v1", "../../outside/secret", ""},
+		{"absolute", "v1", "/abs", ""},
+		{"dot segment", "v1", "a/./b", ""},
+		{"empty segment", "v1", "a//b", ""},
+		{"NUL byte", "v1", "a\x00b", ""},
+		{"backslash", "v1", `..\outside`, ""},
+		{"checksum sidecar", "v1", "chunk.m4s" + checksumSuffix, ""},
+		{"temp file", "v1", ".chunk.m4s" + tempMarker + "1", ""},
+		{"video id linking outside", "escape", "secret", ""},
+		{"new file under a link outside", "escape", "new", ""},
+		{"file linking outside", "v1", "secret", ""},
+		{"relative link outside", "v1", "up/secret", ""},
+		{"dangling link", "v1", "dangling", ""},
+		{"dangling link inside", "v1", "seg/local", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.fullPath(tt.videoId, tt.filename)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Fatalf("fullPath(%q, %q) = %q, %v, want ErrInvalidPath", tt.videoId, tt.filename, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("fullPath(%q, %q) = %q, %v, want %q", tt.videoId, tt.filename, got, err, tt.want)
+			}
+		})
+	}
+}
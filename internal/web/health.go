@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// NodeHealth is what the health checker last concluded about a storage node.
+type NodeHealth int
+
+const (
+	HealthUp      NodeHealth = iota // answering probes
+	HealthSuspect                   // missed at least one probe
+	HealthDown                      // missed downAfter probes in a row, routing skips it
+)
+
+func (h NodeHealth) String() string {
+	switch h {
+	case HealthUp:
+		return "up"
+	case HealthSuspect:
+		return "suspect"
+	case HealthDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// NodeStatus is a node's health along with when it last answered a probe.
+type NodeStatus struct {
+	Health   NodeHealth
+	LastSeen time.Time // zero until the first successful probe
+	Failures int       // consecutive failed probes
+	LastErr  error     // error from the most recent failed probe
+}
+
+// HealthEvent is published every time a node changes state.
+type HealthEvent struct {
+	Node     string
+	From, To NodeHealth
+	At       time.Time
+	Err      error // the probe error that caused the change, nil when coming back up
+}
+
+// healthChecker probes every node the content service has a connection to and keeps their status.
+type healthChecker struct {
+	conns     func() map[string]*nodeConn // current connections, read every round
+	interval  time.Duration
+	timeout   time.Duration
+	downAfter int
+
+	mu     sync.RWMutex
+	status map[string]*NodeStatus
+	subs   []chan HealthEvent
+}
+
+func newHealthChecker(conns func() map[string]*nodeConn, interval, timeout time.Duration, downAfter int) *healthChecker {
+	return &healthChecker{
+		conns:     conns,
+		interval:  interval,
+		timeout:   timeout,
+		downAfter: downAfter,
+		status:    make(map[string]*NodeStatus),
+	}
+}
+
+// run probes all nodes every interval, forever.
+func (h *healthChecker) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.probeAll()
+		<-ticker.C
+	}
+}
+
+// probeAll probes the nodes in parallel so one hung node doesn't delay the others.
+func (h *healthChecker) probeAll() {
+	conns := h.conns()
+	var wg sync.WaitGroup
+	for addr, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.record(addr, h.probe(conn))
+		}()
+	}
+	wg.Wait()
+
+	// forget nodes we no longer have a connection to
+	h.mu.Lock()
+	for addr := range h.status {
+		if _, ok := conns[addr]; !ok {
+			delete(h.status, addr)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// probe runs the standard gRPC health check. A storage server that doesn't register the
+// health service still answered, so Unimplemented counts as up.
+func (h *healthChecker) probe(conn *nodeConn) error {
+	clientConn, _, err := conn.get()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(clientConn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return status.Errorf(codes.Unavailable, "node reports %s", resp.GetStatus())
+	}
+	return nil
+}
+
+// record updates the node's status with one probe result and publishes any change.
+func (h *healthChecker) record(addr string, probeErr error) {
+	now := time.Now()
+	h.mu.Lock()
+	st, ok := h.status[addr]
+	if !ok {
+		st = &NodeStatus{Health: HealthUp}
+		h.status[addr] = st
+	}
+	from := st.Health
+	if probeErr == nil {
+		st.Health, st.LastSeen, st.Failures, st.LastErr = HealthUp, now, 0, nil
+	} else {
+		st.Failures++
+		st.LastErr = probeErr
+		st.Health = HealthSuspect
+		if st.Failures >= h.downAfter {
+			st.Health = HealthDown
+		}
+	}
+	to := st.Health
+	subs := h.subs
+	h.mu.Unlock()
+
+	if from == to {
+		return
+	}
+	ev := HealthEvent{Node: addr, From: from, To: to, At: now, Err: probeErr}
+	log.Printf("storage node %s is %s (was %s)", addr, to, from)
+	for _, ch := range subs {
+		select {
+		case ch <- ev:
+		default: // slow subscriber, drop rather than stall the checker
+		}
+	}
+}
+
+// get returns a copy of the node's status. Nodes not probed yet are reported up.
+func (h *healthChecker) get(addr string) NodeStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if st, ok := h.status[addr]; ok {
+		return *st
+	}
+	return NodeStatus{Health: HealthUp}
+}
+
+func (h *healthChecker) subscribe() <-chan HealthEvent {
+	ch := make(chan HealthEvent, 64)
+	h.mu.Lock()
+	h.subs = append(h.subs, ch)
+	h.mu.Unlock()
+	return ch
+}
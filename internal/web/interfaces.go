@@ -17,3 +17,26 @@ type VideoContentService interface { //VideoContentService is an interface that
 	Read(videoId string, filename string) ([]byte, error)
 	Write(videoId string, filename string, data []byte) error
 }
+
+// RangeReader is a content service that can read part of a file without fetching all of it.
+// The web server uses it for HTTP range requests when the content service has it.
+type RangeReader interface {
+	// ReadRange returns up to length bytes from offset, length 0 reads to the end and a negative
+	// offset counts back from the end. size is that of the whole file, an offset at or past
+	// the end returns no data. Less than asked for may come back, the caller asks again for the rest.
+	ReadRange(videoId, filename string, offset, length int64) (data []byte, size int64, err error)
+}
+
+// clampRange turns a ReadRange offset and length into a start and byte count within size.
+func clampRange(size, offset, length int64) (start, n int64) {
+	if offset < 0 {
+		offset = max(size+offset, 0)
+	}
+	if offset >= size {
+		return size, 0
+	}
+	if length <= 0 || length > size-offset {
+		length = size - offset
+	}
+	return offset, length
+}
@@ -0,0 +1,33 @@
+package web
+
+import "time"
+
+// MigrationRecord is the journal entry for one migration job, enough to run it again after
+// the web server dies halfway. Chunk moves are idempotent, so a rerun simply lists the nodes
+// again and finishes whatever is left, including chunks that ended up on both the old and new owner.
+type MigrationRecord struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`   // add, remove, rebalance or drain
+	Member  Member    `json:"member"` // node being added, removed or drained, empty for a rebalance
+	Before  []Member  `json:"before"` // membership before the change
+	Owner   string    `json:"owner"`  // admin address of the web server running the job
+	State   string    `json:"state"`  // running, failed or canceled, finished jobs are deleted
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+
+	// Rollback is set once the job is undoing the change: the node comes off the ring, or goes back on,
+	// and the chunks moved so far go back to their old owners.
+	Rollback bool `json:"rollback,omitempty"`
+
+	// Speed limits of the job, zero means none.
+	BytesPerSec  int64 `json:"bytes_per_sec,omitempty"`
+	ChunksPerSec int64 `json:"chunks_per_sec,omitempty"`
+}
+
+// MigrationJournal keeps unfinished migrations across restarts of cmd/web.
+// The etcd and SQLite metadata services both implement it.
+type MigrationJournal interface {
+	SaveMigration(rec MigrationRecord) error
+	LoadMigrations() ([]MigrationRecord, error)
+	DeleteMigration(id string) error
+}
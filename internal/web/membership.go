@@ -0,0 +1,123 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// Member is one storage node as recorded in the durable membership list.
+type Member struct {
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+	Drained bool   `json:"drained,omitempty"` // stays on the ring for reads but takes no new writes
+}
+
+// MembershipStore keeps the ring membership across restarts of cmd/web.
+// The etcd and SQLite metadata services both implement it.
+type MembershipStore interface {
+	// LoadMembership returns the stored members, found is false if nothing was ever saved.
+	LoadMembership() (members []Member, found bool, err error)
+	SaveMembership(members []Member) error
+}
+
+// ErrMembershipConflict is returned when the stored membership changed since the one a change was based on.
+var ErrMembershipConflict = errors.New("ring membership was changed by another web server")
+
+// MembershipWatcher is a MembershipStore shared by several web servers.
+// Each of them follows the stored membership so they all route to the same nodes.
+// Saves are conditional on the revision they were based on, so two servers changing the
+// ring at once can't silently overwrite each other.
+type MembershipWatcher interface {
+	// LoadMembershipRev is LoadMembership plus the revision the members were read at, 0 if not found.
+	LoadMembershipRev() (members []Member, rev int64, found bool, err error)
+	// SaveMembershipIf saves members only if the stored membership is still at rev and returns
+	// the new revision. Otherwise it returns ErrMembershipConflict.
+	SaveMembershipIf(members []Member, rev int64) (int64, error)
+	// WatchMembership sends the full membership every time it is saved, until ctx is done.
+	WatchMembership(ctx context.Context) <-chan MembershipUpdate
+}
+
+// MembershipUpdate is one saved membership and the revision it was saved at.
+type MembershipUpdate struct {
+	Members []Member
+	Rev     int64
+}
+
+// sameMembers reports whether two lists hold the same nodes with the same weights, in any order.
+// Drained isn't compared, it can't be set in the nw options.
+func sameMembers(a, b []Member) bool {
+	return slices.EqualFunc(sortedMembers(a), sortedMembers(b), func(x, y Member) bool {
+		return x.Address == y.Address && x.Weight == y.Weight
+	})
+}
+
+// sortedMembers returns a copy of members ordered by address.
+func sortedMembers(members []Member) []Member {
+	members = slices.Clone(members)
+	slices.SortFunc(members, func(a, b Member) int {
+		if a.Address < b.Address {
+			return -1
+		}
+		if a.Address > b.Address {
+			return 1
+		}
+		return 0
+	})
+	return members
+}
+
+// resolveMembership decides which membership the ring starts with.
+// Whatever is stored wins, since it includes AddNode/RemoveNode calls made through cmd/admin.
+// The command line list only seeds the store the first time.
+// rev is the revision of the returned membership for a MembershipWatcher, 0 otherwise.
+func resolveMembership(store MembershipStore, fromOptions []Member) (members []Member, rev int64) {
+	if store == nil {
+		return fromOptions, 0
+	}
+	watcher, shared := store.(MembershipWatcher)
+	var stored []Member
+	var found bool
+	var err error
+	if shared {
+		stored, rev, found, err = watcher.LoadMembershipRev()
+	} else {
+		stored, found, err = store.LoadMembership()
+	}
+	if err != nil {
+		log.Printf("warning: could not load stored ring membership, using the nw options: %v", err)
+		return fromOptions, 0
+	}
+	if !found {
+		if shared {
+			// another web server may be seeding it at the same time, the watch brings whatever won
+			rev, err = watcher.SaveMembershipIf(fromOptions, 0)
+		} else {
+			err = store.SaveMembership(fromOptions)
+		}
+		if err != nil {
+			log.Printf("warning: could not save ring membership: %v", err)
+		}
+		return fromOptions, rev
+	}
+	// stored order matters for jump placement, so compare sorted copies
+	if !sameMembers(stored, fromOptions) {
+		log.Printf("warning: stored ring membership %s differs from the nw options %s, using the stored membership",
+			formatMembers(stored), formatMembers(fromOptions))
+	}
+	return stored, rev
+}
+
+// formatMembers prints members the way they are written in the nw options.
+func formatMembers(members []Member) string {
+	out := ""
+	for i, m := range members {
+		if i > 0 {
+			out += ","
+		}
+		out += fmt.Sprintf("%s@%d", m.Address, m.Weight)
+	}
+	return out
+}
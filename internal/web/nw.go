@@ -6,34 +6,73 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"net"
 	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 	storagepb "tritontube/internal/proto/storage"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-
-	"google.golang.org/grpc"
 )
 
+// DefaultVirtualNodes is how many tokens each storage node gets on the ring when
+// the nw options don't say otherwise. One token per node is the original layout,
+// so clusters that already hold chunks keep routing them to the same place.
+// For an even spread use something like vnodes=160.
+const DefaultVirtualNodes = 1
+
+// ErrNodeFull is returned for uploads a storage node refused because it reached its quota
+// or its free space reserve.
+var ErrNodeFull = errors.New("storage node full")
+
+// StreamFrameSize is the payload size of each frame in the streaming upload and download RPCs.
+// It stays well under gRPC's 4 MB default message limit.
+const StreamFrameSize = 1 << 20
+
+// MaxRangeLength caps what one ReadRange RPC returns, for the same reason.
+const MaxRangeLength = 2 * StreamFrameSize
+
 // ConsistentHashRing interface
 type ConsistentHashRing struct {
 	// This struct would contain the necessary fields for a consistent hash ring.
-	nodes []uint64          // Node identifiers (e.g., hash of node addresses)
-	index map[uint64]string // Maps node identifiers to node addresses
-	mu    sync.RWMutex      // Mutex to protect concurrent access to nodes and index
+	nodes   []uint64            // Sorted ring tokens, vnodes per storage node
+	index   map[uint64]string   // Maps ring tokens to node addresses
+	tokens  map[string][]uint64 // Maps node addresses to the tokens they own
+	weights map[string]int      // Maps node addresses to their capacity weight
+	vnodes  int                 // Tokens placed per unit of weight
+	mu      sync.RWMutex        // Mutex to protect concurrent access to nodes and index
 
 }
 
 // NWVideoContentService implements VideoContentService using a network of nodes.
 type NWVideoContentService struct {
-	Ring         *ConsistentHashRing
-	storageConns map[string]storagepb.StorageServiceClient //gRPC client stubs for each storage node
-	grpcConns    map[string]*grpc.ClientConn               // gRPC connections to each storage node
-	mu           sync.Mutex                                // To protect concurrent access to storageConns
+	// placement and the conn map are never modified in place. A membership change builds new
+	// ones and swaps both under mu, so a request either sees the old view or the new one.
+	placement    Placement
+	storageConns map[string]*nodeConn    // lazily dialed gRPC connection for each storage node
+	drained      map[string]bool         // members taking no new writes, swapped with the placement
+	mu           sync.RWMutex            // To protect the swap of placement and storageConns
+	applyMu      sync.Mutex              // one membership change at a time
+	strategy     string                  // placement= option, for rebuilding the placement
+	vnodes       int                     // ring tokens per unit of weight, for rebuilding the ring
+	replicas     int                     // N: copies of each chunk, on distinct ring successors
+	quorum       int                     // W: acks needed before a write counts as done
+	readTimeout  time.Duration           // deadline for each node Read tries, and for ChecksumOnNode
+	writeTimeout time.Duration           // deadline for each upload to a node
+	membership   MembershipStore         // durable copy of the ring membership, may be nil
+	revision     int64                   // revision of the shared membership the ring is based on, under applyMu
+	health       *healthChecker          // background probes of every node in storageConns
+	dialDefaults DialSettings            // connection settings for nodes without their own
+	nodeDial     map[string]DialSettings // per node connection settings from the nw options
 }
 
 // Uncomment the following line to ensure NetworkVideoContentService implements VideoContentService
@@ -44,173 +83,770 @@ func ComposeKey(videoId, filename string) string {
 }
 
 // routes Read/Write calls over gRPC to one of N storage nodes via consistent hashing.
-func NewNWVideoContentService(contentOptions string) *NWVideoContentService {
+// store may be nil, otherwise the ring starts from the membership saved there and every
+// membership change is written back to it.
+// Nodes are dialed lazily, so an unreachable node doesn't stop the service from starting.
+func NewNWVideoContentService(contentOptions string, store MembershipStore) (*NWVideoContentService, error) {
 	// contentOptions is expected to look like:
 	//   "adminhost:adminport,contenthost1:contentport1,contenthost2:contentport2,..."
+	// followed by optional key=value settings, e.g. ",vnodes=160,replicas=3,quorum=2"
 
 	parts := strings.Split(contentOptions, ",")
 	if len(parts) < 2 {
-		panic(fmt.Sprintf("NWVideoContentService: need at least one admin and one node, got %q", contentOptions))
+		return nil, fmt.Errorf("NWVideoContentService: need at least one admin and one node, got %q", contentOptions)
+	}
+
+	cfg, err := parseNWOptions(parts[1:])
+	if err != nil {
+		return nil, fmt.Errorf("NWVideoContentService: %w", err)
+	}
+	members, rev := resolveMembership(store, cfg.nodes)
+	placement, err := newPlacement(cfg.placement, cfg.vnodes)
+	if err != nil {
+		return nil, fmt.Errorf("NWVideoContentService: %w", err)
+	}
+
+	s := &NWVideoContentService{
+		placement:    placement,
+		storageConns: make(map[string]*nodeConn),
+		strategy:     cfg.placement,
+		vnodes:       cfg.vnodes,
+		replicas:     cfg.replicas,
+		quorum:       cfg.quorum,
+		readTimeout:  cfg.readTimeout,
+		writeTimeout: cfg.writeTimeout,
+		membership:   store,
+		revision:     rev,
+		dialDefaults: cfg.dial,
+		nodeDial:     cfg.nodeDial,
+	}
+	// Initialize the consistent hash ring, connections are made on first use
+	if err := s.applyMembership(members, true); err != nil {
+		return nil, fmt.Errorf("NWVideoContentService: %w", err)
+	}
+
+	s.health = newHealthChecker(func() map[string]*nodeConn {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.storageConns // never modified in place, safe to range over
+	}, cfg.healthInterval, cfg.healthTimeout, cfg.downAfter)
+	go s.health.run()
+
+	// with etcd every web server shares the stored membership, follow changes made through the others
+	if watcher, ok := store.(MembershipWatcher); ok {
+		go s.followMembership(watcher)
 	}
+	return s, nil
+}
+
+// ReplicationFactor is how many nodes each chunk is written to.
+func (s *NWVideoContentService) ReplicationFactor() int {
+	return s.replicas
+}
+
+// Placement returns the current placement. Treat it as read-only, it is replaced rather than
+// changed when membership changes, so holding on to it gives a consistent snapshot.
+func (s *NWVideoContentService) Placement() Placement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.placement
+}
+
+// NewPlacement builds a placement of the configured kind holding members,
+// for working out where keys would go after a membership change.
+func (s *NWVideoContentService) NewPlacement(members []Member) (Placement, error) {
+	placement, err := newPlacement(s.strategy, s.vnodes)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if err := placement.AddWeighted(m.Address, m.Weight); err != nil {
+			return nil, err
+		}
+	}
+	return placement, nil
+}
 
-	nodeAddrs := parts[1:]
+// client returns the gRPC stub for a node, dialing it if this is the first call.
+func (s *NWVideoContentService) client(nodeAddr string) (storagepb.StorageServiceClient, error) {
+	s.mu.RLock()
+	conn, exists := s.storageConns[nodeAddr]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("node %q not found in storage connections", nodeAddr)
+	}
+	_, client, err := conn.get()
+	return client, err
+}
 
-	// Initialize the consistent hash ring
-	// chunk nodes into a ring
-	ring := NewConsistentHashRing()
+// dialSettings returns the connection settings for a node.
+func (s *NWVideoContentService) dialSettings(nodeAddr string) DialSettings {
+	if settings, ok := s.nodeDial[nodeAddr]; ok {
+		return settings
+	}
+	return s.dialDefaults
+}
+
+// NodeStatus returns what the health checker last saw of the node.
+func (s *NWVideoContentService) NodeStatus(nodeAddr string) NodeStatus {
+	return s.health.get(nodeAddr)
+}
+
+// SubscribeHealth returns a channel that receives every node state change.
+// Events are dropped if the channel isn't drained.
+func (s *NWVideoContentService) SubscribeHealth() <-chan HealthEvent {
+	return s.health.subscribe()
+}
+
+// skipDown drops nodes the health checker considers down, keeping the order.
+// If every node is down they are all returned, a stale verdict is better than not trying.
+func (s *NWVideoContentService) skipDown(nodeAddrs []string) []string {
+	live := make([]string, 0, len(nodeAddrs))
 	for _, addr := range nodeAddrs {
-		ring.Add(addr) // Add each node's address to the ring as a consistent hash
+		if s.health.get(addr).Health != HealthDown {
+			live = append(live, addr)
+		}
+	}
+	if len(live) == 0 {
+		return nodeAddrs
 	}
+	return live
+}
 
-	//dial each node once and store the client stub
-	conns := make(map[string]storagepb.StorageServiceClient, len(nodeAddrs))
-	grpcConns := make(map[string]*grpc.ClientConn, len(nodeAddrs))
+// skipDrained drops drained nodes, so their share of new writes goes to the next nodes on the ring.
+func (s *NWVideoContentService) skipDrained(nodeAddrs []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	open := make([]string, 0, len(nodeAddrs))
 	for _, addr := range nodeAddrs {
-		//var opts []grpc.DialOption - no need for secure connection in this example
-		clientConn, err := grpc.NewClient(addr, grpc.WithInsecure())
-		if err != nil {
-			panic(fmt.Sprintf("NWVideoContentService: failed to dial node %s: %v", addr, err))
+		if !s.drained[addr] {
+			open = append(open, addr)
 		}
-		// give grpc the conn, get the stub where we can call gRPC methods on that server
-		conns[addr] = storagepb.NewStorageServiceClient(clientConn)
-		grpcConns[addr] = clientConn // Store the gRPC connection for later use
 	}
+	if len(open) == 0 {
+		return nodeAddrs
+	}
+	return open
+}
 
-	//dial each node once and store the client stub
-	return &NWVideoContentService{
-		Ring:         ring,
-		storageConns: conns,     // Store the gRPC clients for each node
-		grpcConns:    grpcConns, // Store the gRPC connections for each node
+// Drained reports whether the node is drained, see SetDrained.
+func (s *NWVideoContentService) Drained(nodeAddr string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.drained[nodeAddr]
+}
+
+// SetDrained takes a node out of write rotation, or puts it back, and saves the membership.
+// A drained node keeps its place on the ring so reads still reach the chunks it holds.
+func (s *NWVideoContentService) SetDrained(nodeAddr string, drained bool) error {
+	return s.changeMembership(func(members []Member) ([]Member, error) {
+		i := slices.IndexFunc(members, func(m Member) bool { return m.Address == nodeAddr })
+		if i < 0 {
+			return nil, status.Errorf(codes.NotFound, "node %s not found in the ring", nodeAddr)
+		}
+		members[i].Drained = drained
+		return members, nil
+	})
+}
+
+// Members returns the ring membership as it would be saved.
+func (s *NWVideoContentService) Members() []Member {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return membersOf(s.placement, s.drained)
+}
+
+func membersOf(placement Placement, drained map[string]bool) []Member {
+	addrs := placement.List()
+	members := make([]Member, 0, len(addrs))
+	for _, addr := range addrs {
+		members = append(members, Member{Address: addr, Weight: placement.Weight(addr), Drained: drained[addr]})
 	}
+	return members
 }
 
-// register corresponding gRPC server and add stub to the map
-func (s *NWVideoContentService) RegisterNode(nodeAddr string) {
-	//var opts []grpc.DialOption - no need for secure connection in this example
-	clientConn, err := grpc.NewClient(nodeAddr, grpc.WithInsecure())
+// AddMember puts a node in the placement and saves the new membership.
+func (s *NWVideoContentService) AddMember(m Member) error {
+	return s.changeMembership(func(members []Member) ([]Member, error) {
+		return membersWith(members, m)
+	})
+}
+
+// RemoveMember takes a node out of the placement and saves the new membership.
+// Its connection stays open so its chunks can still be migrated off, call DeregisterNode when done.
+func (s *NWVideoContentService) RemoveMember(nodeAddr string) error {
+	return s.changeMembership(func(members []Member) ([]Member, error) {
+		return membersWithout(members, nodeAddr)
+	})
+}
+
+// MembersWith returns the membership AddMember(m) would switch to, without changing anything.
+func (s *NWVideoContentService) MembersWith(m Member) ([]Member, error) {
+	return membersWith(s.Members(), m)
+}
+
+// MembersWithout returns the membership RemoveMember(nodeAddr) would switch to, without changing anything.
+func (s *NWVideoContentService) MembersWithout(nodeAddr string) ([]Member, error) {
+	return membersWithout(s.Members(), nodeAddr)
+}
+
+func membersWith(members []Member, m Member) ([]Member, error) {
+	if _, _, err := net.SplitHostPort(m.Address); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "node address %q: %v", m.Address, err)
+	}
+	if slices.ContainsFunc(members, func(x Member) bool { return x.Address == m.Address }) {
+		return nil, status.Errorf(codes.AlreadyExists, "node %s already exists in the ring", m.Address)
+	}
+	return append(members, m), nil
+}
+
+func membersWithout(members []Member, nodeAddr string) ([]Member, error) {
+	remaining := slices.DeleteFunc(slices.Clone(members), func(x Member) bool { return x.Address == nodeAddr })
+	if len(remaining) == len(members) {
+		return nil, status.Errorf(codes.NotFound, "node %s not found in the ring", nodeAddr)
+	}
+	return remaining, nil
+}
+
+// changeMembership builds the new membership from the current one with edit, applies it
+// locally and then saves it. Applying first means the watch event for our own save finds
+// nothing to do. applyMu is held throughout, so a membership arriving from the watch can't
+// land between reading the current ring and saving the edited one.
+// If the save fails the old ring goes back. A shared store refuses the save if another web
+// server changed the membership in the meantime, then the ring switches to the stored one
+// and the caller gets an Aborted error, its plan was made against a ring that's gone.
+func (s *NWVideoContentService) changeMembership(edit func([]Member) ([]Member, error)) error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	old := s.Members()
+	members, err := edit(slices.Clone(old))
 	if err != nil {
-		panic(fmt.Sprintf("NWVideoContentService: failed to dial node %s: %v", nodeAddr, err))
+		return err
 	}
-	// add to the map key: nodeAddr, value: gRPC client stub
-	s.mu.Lock() // lock the mutex to protect concurrent access
-	defer s.mu.Unlock()
-	s.storageConns[nodeAddr] = storagepb.NewStorageServiceClient(clientConn)
-	s.grpcConns[nodeAddr] = clientConn // Store the gRPC connection for later use
+	if err := s.applyMembershipLocked(members, false); err != nil {
+		return err
+	}
+	if s.membership == nil {
+		return nil
+	}
+	watcher, shared := s.membership.(MembershipWatcher)
+	if !shared {
+		err = s.membership.SaveMembership(members)
+	} else {
+		var rev int64
+		if rev, err = watcher.SaveMembershipIf(members, s.revision); err == nil {
+			s.revision = rev
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	conflict := errors.Is(err, ErrMembershipConflict)
+	if !conflict || !s.reloadMembershipLocked(watcher) {
+		// don't keep a ring change that won't survive a restart
+		if rerr := s.applyMembershipLocked(old, false); rerr != nil {
+			log.Printf("failed to restore ring after save error: %v", rerr)
+		}
+	}
+	if conflict {
+		return status.Errorf(codes.Aborted, "%v, try again", err)
+	}
+	return fmt.Errorf("failed to save ring membership: %w", err)
 }
 
-// DeregisterNode closes its gRPC connection
-func (s *NWVideoContentService) DeregisterNode(nodeAddr string) {
+// reloadMembershipLocked switches the ring to the stored membership after a conflicting save,
+// without waiting for the watch to bring it. Reports whether that worked.
+func (s *NWVideoContentService) reloadMembershipLocked(watcher MembershipWatcher) bool {
+	stored, rev, found, err := watcher.LoadMembershipRev()
+	if err != nil || !found {
+		log.Printf("failed to reload ring membership after a conflict: %v", err)
+		return false
+	}
+	if err := s.applyMembershipLocked(stored, false); err != nil {
+		log.Printf("failed to apply reloaded ring membership: %v", err)
+		return false
+	}
+	s.revision = rev
+	log.Printf("ring membership was changed elsewhere, now %s", formatMembers(stored))
+	return true
+}
+
+// applyMembership swaps in a placement built from members, adding a connection for any new node.
+// With closeDeparted, connections to nodes that left are closed too. Admin changes leave
+// them open because the chunks on a removed node still have to be read off it.
+func (s *NWVideoContentService) applyMembership(members []Member, closeDeparted bool) error {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	return s.applyMembershipLocked(members, closeDeparted)
+}
+
+// applyMembershipLocked is applyMembership for callers already holding applyMu.
+func (s *NWVideoContentService) applyMembershipLocked(members []Member, closeDeparted bool) error {
+	placement, err := s.NewPlacement(members)
+	if err != nil {
+		return err
+	}
+	drained := make(map[string]bool)
+	for _, m := range members {
+		if m.Drained {
+			drained[m.Address] = true
+		}
+	}
+	if slices.Equal(s.Members(), membersOf(placement, drained)) {
+		// nothing changed, e.g. the watch event for a change this server made itself
+		return nil
+	}
+
+	s.mu.RLock()
+	conns := maps.Clone(s.storageConns)
+	s.mu.RUnlock()
+	for _, m := range members {
+		if _, ok := conns[m.Address]; !ok {
+			conns[m.Address] = newNodeConn(m.Address, s.dialSettings(m.Address))
+		}
+	}
+	var departed []*nodeConn
+	if closeDeparted {
+		for addr, conn := range conns {
+			if placement.Weight(addr) == 0 {
+				departed = append(departed, conn)
+				delete(conns, addr)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.placement, s.storageConns, s.drained = placement, conns, drained
+	s.mu.Unlock()
+
+	for _, conn := range departed {
+		conn.close()
+	}
+	return nil
+}
+
+// followMembership applies membership changes saved by other web servers until the watch ends.
+// Updates older than the revision the ring is already at are skipped, e.g. one that was in
+// flight while this server saved its own change.
+func (s *NWVideoContentService) followMembership(watcher MembershipWatcher) {
+	for update := range watcher.WatchMembership(context.Background()) {
+		s.followUpdate(update)
+	}
+}
+
+func (s *NWVideoContentService) followUpdate(update MembershipUpdate) {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	if update.Rev < s.revision {
+		return
+	}
+	before := s.Members()
+	if err := s.applyMembershipLocked(update.Members, true); err != nil {
+		log.Printf("failed to apply ring membership from store: %v", err)
+		return
+	}
+	s.revision = update.Rev
+	if after := s.Members(); !slices.Equal(before, after) {
+		log.Printf("ring membership changed to %s", formatMembers(after))
+	}
+}
+
+// RegisterNode sets up a connection to a node that isn't on the ring, so chunks can be
+// migrated off it, e.g. when a removal is resumed after a restart. No-op if it has one.
+func (s *NWVideoContentService) RegisterNode(nodeAddr string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, ok := s.storageConns[nodeAddr]; ok {
+		return
+	}
+	s.storageConns = maps.Clone(s.storageConns)
+	s.storageConns[nodeAddr] = newNodeConn(nodeAddr, s.dialSettings(nodeAddr))
+}
 
-	if clientConn, ok := s.grpcConns[nodeAddr]; ok {
-		clientConn.Close()            // actually close the network connection
-		delete(s.grpcConns, nodeAddr) // remove from your map
+// DeregisterNode closes its gRPC connection, once the node is off the ring and its chunks are moved
+func (s *NWVideoContentService) DeregisterNode(nodeAddr string) {
+	s.mu.Lock()
+	conn, ok := s.storageConns[nodeAddr]
+	ok = ok && s.placement.Weight(nodeAddr) == 0 // still a member, keep it
+	if ok {
+		s.storageConns = maps.Clone(s.storageConns)
+		delete(s.storageConns, nodeAddr) // remove from your map
+	}
+	s.mu.Unlock()
+	if ok {
+		conn.close()
 	}
-	delete(s.storageConns, nodeAddr)
 }
 
 // Add the new node address to the consistent hash ring}
 
-func (s *NWVideoContentService) DeleteFile(videoId string, filename string, nodeAddr string) error {
+// DeleteFile deletes the chunk from one node, giving up when ctx is done or the read timeout runs out.
+func (s *NWVideoContentService) DeleteFile(ctx context.Context, videoId string, filename string, nodeAddr string) error {
 	//get the gRPC server stub
-	client := s.storageConns[nodeAddr]
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.readTimeout)
+	defer cancel()
 
 	// run gRPC call on server
 	//fs operations at videoId/filename
 	//baseDir provided by the server
-	_, err := client.DeleteFile(context.Background(), &storagepb.DeleteRequest{
+	_, err = client.DeleteFile(ctx, &storagepb.DeleteRequest{
 		Key: ComposeKey(videoId, filename), // use key as the identifier for the file
 	})
 	return err
 }
 
-// Identify the write node based on consistent hashing
-// write to it
+// Identify the write nodes based on consistent hashing
+// the chunk goes to the first N distinct nodes clockwise from its key that aren't down, in parallel
+// write succeeds once W of them have acknowledged, and returns right then. The other uploads
+// carry on in the background, so data must not be changed after Write returns.
 func (s *NWVideoContentService) Write(videoId, filename string, data []byte) error {
 	key := fmt.Sprintf("%s/%s", videoId, filename) // Create a key based on videoId and filename
-	nodeAddr, err := s.Ring.GetNodeForKey(key)     // Get the node address for the key
+	placement := s.Placement()
+	nodeAddrs, err := placement.GetNodesForKey(key, len(placement.List())) // every node in preference order
 	if err != nil {
 		return fmt.Errorf("no nodes in this ring.  failed to get node for key %s: %v", key, err)
 	}
+	nodeAddrs = s.skipDown(s.skipDrained(nodeAddrs))
+	spares := nodeAddrs[min(s.replicas, len(nodeAddrs)):]   // the rest of the ring, in case a replica is full
+	nodeAddrs = nodeAddrs[:min(s.replicas, len(nodeAddrs))] // the replica set for the key
 
-	//get the gRPC server stub
-	client := s.storageConns[nodeAddr]
+	// a full node hands its copy to the next node on the ring that isn't in the replica set
+	// yet, reads go past the owners anyway and a rebalance moves it back once there is room
+	var sparesMu sync.Mutex
+	nextSpare := func() (string, bool) {
+		sparesMu.Lock()
+		defer sparesMu.Unlock()
+		if len(spares) == 0 {
+			return "", false
+		}
+		next := spares[0]
+		spares = spares[1:]
+		return next, true
+	}
 
-	// run gRPC call on server
-	//fs operations at videoId/filename
-	//baseDir provided by the server
-	_, err = client.UploadFile(context.Background(), &storagepb.UploadRequest{
-		Key:  key,  // use key as the identifier for the file
-		Data: data, // data to write
-	})
+	errs := make(chan error, len(nodeAddrs))
+	for _, nodeAddr := range nodeAddrs {
+		go func() {
+			for {
+				err := s.WriteToNode(context.Background(), videoId, filename, data, nodeAddr)
+				if err == nil {
+					errs <- nil
+					return
+				}
+				next, ok := "", false
+				if errors.Is(err, ErrNodeFull) {
+					next, ok = nextSpare()
+				}
+				if !ok {
+					errs <- fmt.Errorf("%s: %w", nodeAddr, err)
+					return
+				}
+				log.Printf("write %s: %s is full, trying %s", key, nodeAddr, next)
+				nodeAddr = next
+			}
+		}()
+	}
+
+	// done as soon as W replicas have it, or as soon as too many failed for W to be reached,
+	// the uploads still running finish in the background (errs has room for all of them)
+	acks := 0
+	var failures []error
+	for acks < s.quorum && len(nodeAddrs)-len(failures) >= s.quorum {
+		if err := <-errs; err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		acks++
+	}
+	if pending := len(nodeAddrs) - acks - len(failures); pending > 0 {
+		go func() {
+			for range pending {
+				if err := <-errs; err != nil {
+					log.Printf("write %s: replica failed after the write returned: %v", key, err)
+				}
+			}
+		}()
+	}
+	if acks < s.quorum {
+		return fmt.Errorf("write %s reached %d of %d required replicas: %w", key, acks, s.quorum, errors.Join(failures...))
+	}
+	if len(failures) > 0 {
+		log.Printf("write %s: quorum met but %d replica(s) failed: %v", key, len(failures), errors.Join(failures...))
+	}
+	return nil
+}
+
+// WriteToNode uploads the chunk to a specific node, bypassing the ring.
+// Migrations use it to place a chunk on its new owner directly.
+// It gives up when ctx is done or the write timeout runs out.
+func (s *NWVideoContentService) WriteToNode(ctx context.Context, videoId, filename string, data []byte, nodeAddr string) error {
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	key := ComposeKey(videoId, filename) // use key as the identifier for the file
+	sum := sha256.Sum256(data)           // the node checks what it received against it and keeps it
+	err = uploadStream(ctx, client, key, data, sum[:])
+	if status.Code(err) == codes.Unimplemented {
+		// storage server predates the streaming RPCs, send it in one message
+		// run gRPC call on server
+		//fs operations at videoId/filename
+		//baseDir provided by the server
+		_, err = client.UploadFile(ctx, &storagepb.UploadRequest{
+			Key:    key,  // use key as the identifier for the file
+			Data:   data, // data to write
+			Sha256: sum[:],
+		})
+	}
+	if status.Code(err) == codes.ResourceExhausted {
+		return fmt.Errorf("%w: %s", ErrNodeFull, status.Convert(err).Message())
+	}
+	return err
+}
+
+// uploadStream sends data to the node in StreamFrameSize frames.
+func uploadStream(ctx context.Context, client storagepb.StorageServiceClient, key string, data, sum []byte) error {
+	stream, err := client.UploadFileStream(ctx)
+	if err != nil {
+		return err
+	}
+	for off := 0; off == 0 || off < len(data); off += StreamFrameSize {
+		frame := &storagepb.UploadChunk{Data: data[off:min(off+StreamFrameSize, len(data))]}
+		if off == 0 {
+			frame.Key, frame.Sha256 = key, sum // only the first frame carries the key and digest
+		}
+		if err := stream.Send(frame); err != nil {
+			break // the real error comes back from CloseAndRecv
+		}
+	}
+	_, err = stream.CloseAndRecv()
 	return err
 }
 
+// ChecksumOnNode returns the SHA-256 of the chunk as the node has it. A node without the
+// ChecksumFile RPC sends the chunk back and it is hashed here. Either way it gets the read
+// timeout on top of whatever deadline ctx has.
+func (s *NWVideoContentService) ChecksumOnNode(ctx context.Context, videoId, filename, nodeAddr string) ([]byte, error) {
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.readTimeout)
+	defer cancel()
+	key := fmt.Sprintf("%s/%s", videoId, filename)
+	resp, err := client.ChecksumFile(ctx, &storagepb.ChecksumRequest{Key: key})
+	if status.Code(err) == codes.Unimplemented {
+		data, err := s.downloadFromNode(ctx, key, nodeAddr)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ChecksumFile RPC to %s for %s failed: %v", nodeAddr, key, err)
+	}
+	return resp.GetSha256(), nil
+}
+
+// NodeStats asks the node what it stores and how much disk it has left.
+func (s *NWVideoContentService) NodeStats(ctx context.Context, nodeAddr string) (*storagepb.GetStatsResponse, error) {
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.GetStats(ctx, &storagepb.GetStatsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("GetStats RPC to %s failed: %v", nodeAddr, err)
+	}
+	return resp, nil
+}
+
 // ListChunks lists all chunks for a given server addr
 // we need this for add and remove server so we can reassign chunks
-func (s *NWVideoContentService) ListChunkNames(nodeAddr string) ([]string, error) {
-	s.mu.Lock()
-	client, exists := s.storageConns[nodeAddr]
-	s.mu.Unlock()
-	if !exists {
-		return nil, fmt.Errorf("node %q not found in storage connections", nodeAddr)
+func (s *NWVideoContentService) ListChunkNames(ctx context.Context, nodeAddr string) ([]string, error) {
+	keys, _, err := s.ListChunkSizes(ctx, nodeAddr)
+	return keys, err
+}
+
+// ListChunkSizes is ListChunkNames with the size of each chunk in bytes, same order.
+// sizes is nil if the storage server doesn't report them. A node with many chunks takes a while
+// to list, so the call gets the write timeout rather than the read one.
+func (s *NWVideoContentService) ListChunkSizes(ctx context.Context, nodeAddr string) (keys []string, sizes []int64, err error) {
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return nil, nil, err
 	}
+	ctx, cancel := context.WithTimeout(ctx, s.writeTimeout)
+	defer cancel()
 	// Call the ListFiles RPC on that storage node
-	resp, err := client.ListFiles(context.Background(), &storagepb.ListFilesRequest{})
+	resp, err := client.ListFiles(ctx, &storagepb.ListFilesRequest{})
 	if err != nil {
-		return nil, fmt.Errorf("ListFiles RPC to %s failed: %v", nodeAddr, err)
+		return nil, nil, fmt.Errorf("ListFiles RPC to %s failed: %v", nodeAddr, err)
 	}
-
-	return resp.GetKeys(), nil
+	if len(resp.GetSizes()) != len(resp.GetKeys()) {
+		return resp.GetKeys(), nil, nil
+	}
+	return resp.GetKeys(), resp.GetSizes(), nil
 }
 
-// Read gets the content
-func (s *NWVideoContentService) ReadFromNode(videoId, filename string, nodeAddr string) ([]byte, error) {
+// Read gets the content from one node, giving up when ctx is done or the read timeout runs out
+func (s *NWVideoContentService) ReadFromNode(ctx context.Context, videoId, filename string, nodeAddr string) ([]byte, error) {
 	key := fmt.Sprintf("%s/%s", videoId, filename) // Create a key based on videoId and filename
+	ctx, cancel := context.WithTimeout(ctx, s.readTimeout)
+	defer cancel()
+	data, err := s.downloadFromNode(ctx, key, nodeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s from node %s: %w", key, nodeAddr, err)
+	}
+	return data, nil
+}
 
+// downloadFromNode fetches one chunk from one node, giving up when ctx expires.
+func (s *NWVideoContentService) downloadFromNode(ctx context.Context, key, nodeAddr string) ([]byte, error) {
 	//get the gRPC client for the node
-	client := s.storageConns[nodeAddr]
-	resp, err := client.DownloadFile(context.Background(), &storagepb.DownloadRequest{
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := downloadStream(ctx, client, key)
+	if status.Code(err) != codes.Unimplemented {
+		return data, err
+	}
+	// storage server predates the streaming RPCs, fetch it in one message
+	resp, err := client.DownloadFile(ctx, &storagepb.DownloadRequest{
 		Key: key, // use key as the identifier for the file
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file %s from node %s: %v", key, nodeAddr, err)
+		return nil, err
 	}
 	if !resp.Found {
-		return nil, fmt.Errorf("file %s not found on node %s", key, nodeAddr)
+		return nil, fmt.Errorf("file %s not found", key)
 	}
 	return resp.Data, nil
+}
 
+// downloadStream collects the frames of a DownloadFileStream.
+func downloadStream(ctx context.Context, client storagepb.StorageServiceClient, key string) ([]byte, error) {
+	stream, err := client.DownloadFileStream(ctx, &storagepb.DownloadRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		frame, err := stream.Recv()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, frame.GetData()...)
+	}
 }
 
 // Read gets the content
+// starts at the key's owner and keeps walking clockwise to the next distinct node
+// until one of them has the chunk, each attempt gets its own deadline so one hung
+// node can't eat the whole request
 func (s *NWVideoContentService) Read(videoId, filename string) ([]byte, error) {
-	key := fmt.Sprintf("%s/%s", videoId, filename) // Create a key based on videoId and filename
-	nodeAddr, err := s.Ring.GetNodeForKey(key)     // Get the node address for the key
+	key := fmt.Sprintf("%s/%s", videoId, filename)                         // Create a key based on videoId and filename
+	placement := s.Placement()                                             // one snapshot for the whole read
+	nodeAddrs, err := placement.GetNodesForKey(key, len(placement.List())) // owner first, then its successors
 	if err != nil {
 		return nil, fmt.Errorf("no nodes in this ring.  failed to get node for key %s: %v", key, err)
 	}
+	nodeAddrs = s.skipDown(nodeAddrs)
 
-	//get the gRPC client for the node
-	client := s.storageConns[nodeAddr]
-	resp, err := client.DownloadFile(context.Background(), &storagepb.DownloadRequest{
-		Key: key, // use key as the identifier for the file
-	})
+	var failures []error
+	for _, nodeAddr := range nodeAddrs {
+		ctx, cancel := context.WithTimeout(context.Background(), s.readTimeout)
+		data, err := s.downloadFromNode(ctx, key, nodeAddr)
+		cancel()
+		if err == nil {
+			return data, nil
+		}
+		if status.Code(err) == codes.DataLoss {
+			// the node's copy is corrupt, as good as missing, the next replica may be fine
+			log.Printf("read %s: corrupt replica on %s, trying the next node", key, nodeAddr)
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", nodeAddr, err))
+	}
+	return nil, fmt.Errorf("failed to read %s, tried %d node(s): %w", key, len(nodeAddrs), errors.Join(failures...))
+}
+
+var _ RangeReader = (*NWVideoContentService)(nil)
+
+// ReadRange reads part of a chunk, failing over between replicas like Read, a node that
+// knows its copy is corrupt answers DataLoss and the next one is tried. A node without
+// the ReadRange RPC sends the whole chunk and it is cut here.
+func (s *NWVideoContentService) ReadRange(videoId, filename string, offset, length int64) ([]byte, int64, error) {
+	key := fmt.Sprintf("%s/%s", videoId, filename)
+	placement := s.Placement()
+	nodeAddrs, err := placement.GetNodesForKey(key, len(placement.List()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file %s from node %s: %v", key, nodeAddr, err)
+		return nil, 0, fmt.Errorf("no nodes in this ring.  failed to get node for key %s: %v", key, err)
 	}
-	if !resp.Found {
-		return nil, fmt.Errorf("file %s not found on node %s", key, nodeAddr)
+	nodeAddrs = s.skipDown(nodeAddrs)
+
+	var failures []error
+	for _, nodeAddr := range nodeAddrs {
+		ctx, cancel := context.WithTimeout(context.Background(), s.readTimeout)
+		data, size, err := s.readRangeFromNode(ctx, key, nodeAddr, offset, length)
+		cancel()
+		if err == nil {
+			return data, size, nil
+		}
+		if status.Code(err) == codes.DataLoss {
+			log.Printf("read range of %s: corrupt replica on %s, trying the next node", key, nodeAddr)
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", nodeAddr, err))
 	}
-	return resp.Data, nil
+	return nil, 0, fmt.Errorf("failed to read %s, tried %d node(s): %w", key, len(nodeAddrs), errors.Join(failures...))
+}
 
+func (s *NWVideoContentService) readRangeFromNode(ctx context.Context, key, nodeAddr string, offset, length int64) ([]byte, int64, error) {
+	client, err := s.client(nodeAddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.ReadRange(ctx, &storagepb.ReadRangeRequest{Key: key, Offset: offset, Length: length})
+	if status.Code(err) != codes.Unimplemented {
+		return resp.GetData(), resp.GetTotalSize(), err
+	}
+	// storage server predates ReadRange
+	data, err := s.downloadFromNode(ctx, key, nodeAddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := int64(len(data))
+	start, n := clampRange(size, offset, length)
+	return data[start : start+n], size, nil
 }
 
 func NewConsistentHashRing() *ConsistentHashRing {
+	return NewVirtualNodeRing(DefaultVirtualNodes)
+}
+
+// NewVirtualNodeRing returns an empty ring that places vnodes tokens per node.
+func NewVirtualNodeRing(vnodes int) *ConsistentHashRing {
+	if vnodes < 1 {
+		vnodes = 1
+	}
 	return &ConsistentHashRing{
-		nodes: make([]uint64, 0), // init empty slice
-		index: make(map[uint64]string),
+		nodes:   make([]uint64, 0), // init empty slice
+		index:   make(map[uint64]string),
+		tokens:  make(map[string][]uint64),
+		weights: make(map[string]int),
+		vnodes:  vnodes,
 	}
 }
 
@@ -219,42 +855,71 @@ func hashStringToUint64(s string) uint64 {
 	return binary.BigEndian.Uint64(sum[:8]) // convert the first 8 bytes of the hash to a uint64
 }
 
+// vnodeHash is the ring position of a node's i-th virtual node.
+// Token 0 is the plain address hash, which is where the node sat before vnodes existed.
+func vnodeHash(nodeAddr string, i int) uint64 {
+	if i == 0 {
+		return hashStringToUint64(nodeAddr)
+	}
+	return hashStringToUint64(fmt.Sprintf("%s#%d", nodeAddr, i))
+}
+
 // add a method to the struct consistentHashRing to add nodes.  The paraenthesis and r and pointer to the struct tells us this is a method of the struct we are defining
 func (r *ConsistentHashRing) Add(nodeAddr string) error {
-	h := hashStringToUint64(nodeAddr) // hash the node address to a uint64
-	r.mu.Lock()                       // lock the mutex to protect concurrent access
-	defer r.mu.Unlock()               // unlock the mutex when the function returns
-	// If this hash is already in r.index, do nothing
-	if _, exists := r.index[h]; exists {
+	return r.AddWeighted(nodeAddr, 1)
+}
+
+// AddWeighted adds a node with vnodes*weight tokens, so a node of weight 2
+// ends up owning about twice the keyspace of a node of weight 1.
+func (r *ConsistentHashRing) AddWeighted(nodeAddr string, weight int) error {
+	if err := ValidateWeight(nodeAddr, weight); err != nil {
+		return err
+	}
+	r.mu.Lock()         // lock the mutex to protect concurrent access
+	defer r.mu.Unlock() // unlock the mutex when the function returns
+	// If this node is already on the ring, do nothing
+	if _, exists := r.tokens[nodeAddr]; exists {
 		return status.Errorf(codes.AlreadyExists, "node %s already exists in the ring", nodeAddr)
 	}
-	r.index[h] = nodeAddr
 
-	r.nodes = append(r.nodes, h) // add the hash to the nodes slice
-	sort.Slice(r.nodes, func(i, j int) bool {
-		return r.nodes[i] < r.nodes[j] // sort the nodes slice in ascending order for each node hash of type int64
-	})
+	count := r.vnodes * weight
+	owned := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		h := vnodeHash(nodeAddr, i) // hash the virtual node to a uint64
+		if _, taken := r.index[h]; taken {
+			// a 64 bit collision with another node's token, just skip this one
+			continue
+		}
+		r.index[h] = nodeAddr
+		owned = append(owned, h)
+		r.nodes = append(r.nodes, h) // add the hash to the nodes slice
+	}
+	r.tokens[nodeAddr] = owned
+	r.weights[nodeAddr] = weight
+	slices.Sort(r.nodes) // keep the tokens in ascending order for binary search
 	return nil
 }
 
 // remove
 func (r *ConsistentHashRing) Remove(nodeAddr string) error {
-	h := hashStringToUint64(nodeAddr) // hash the node address to a uint64
-	r.mu.Lock()                       // not convinced this is needed, since there is only one admin server that modifies the ring
+	r.mu.Lock() // not convinced this is needed, since there is only one admin server that modifies the ring
 	// if we have multiple admin servers, i could see the need
 	defer r.mu.Unlock()
 
-	if _, exists := r.index[h]; !exists {
+	owned, exists := r.tokens[nodeAddr]
+	if !exists {
 		return status.Errorf(codes.NotFound, "node %s not found in the ring", nodeAddr)
 	}
-	delete(r.index, h)
-	// Remove h from the slice
-	for i, v := range r.nodes {
-		if v == h {
-			r.nodes = slices.Delete(r.nodes, i, i+1) // remove the hash from the nodes slice
-			break
-		}
+	for _, h := range owned {
+		delete(r.index, h)
 	}
+	delete(r.tokens, nodeAddr)
+	delete(r.weights, nodeAddr)
+	// Remove the node's tokens from the slice, order is preserved
+	r.nodes = slices.DeleteFunc(r.nodes, func(h uint64) bool {
+		_, ok := r.index[h]
+		return !ok
+	})
 	return nil
 }
 
@@ -279,13 +944,88 @@ func (r *ConsistentHashRing) GetNodeForKey(key string) (string, error) {
 	return r.index[r.nodes[idx]], nil // return the node address for the hash at index idx
 }
 
+// GetNodesForKey walks clockwise from the key and returns the first n distinct nodes.
+// The first entry is the same node GetNodeForKey returns. Fewer than n come back if the ring is smaller.
+func (r *ConsistentHashRing) GetNodesForKey(key string, n int) ([]string, error) {
+	h := hashStringToUint64(key)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.nodes) == 0 {
+		return nil, fmt.Errorf("no nodes available in the ring")
+	}
+	n = min(n, len(r.tokens))
+	idx := sort.Search(len(r.nodes), func(i int) bool {
+		return r.nodes[i] >= h
+	})
+	owners := make([]string, 0, n)
+	// vnodes of the same node sit next to each other now and then, skip repeats
+	for i := 0; i < len(r.nodes) && len(owners) < n; i++ {
+		addr := r.index[r.nodes[(idx+i)%len(r.nodes)]]
+		if !slices.Contains(owners, addr) {
+			owners = append(owners, addr)
+		}
+	}
+	return owners, nil
+}
+
+// List returns each physical node once, sorted by address.
 func (r *ConsistentHashRing) List() []string {
 	r.mu.RLock()         // lock the mutex to protect concurrent access
 	defer r.mu.RUnlock() // unlock the mutex when the function returns
 
-	nodes := make([]string, len(r.nodes))
-	for i, h := range r.nodes {
-		nodes[i] = r.index[h] // map the hash to the node address
+	nodes := make([]string, 0, len(r.tokens))
+	for addr := range r.tokens {
+		nodes = append(nodes, addr)
 	}
+	sort.Strings(nodes)
 	return nodes
 }
+
+// Clone returns an independent copy of the ring.
+// The admin server keeps one from before a membership change to work out which keys moved.
+func (r *ConsistentHashRing) Clone() *ConsistentHashRing {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c := NewVirtualNodeRing(r.vnodes)
+	c.nodes = slices.Clone(r.nodes)
+	for h, addr := range r.index {
+		c.index[h] = addr
+	}
+	for addr, owned := range r.tokens {
+		c.tokens[addr] = slices.Clone(owned)
+	}
+	for addr, w := range r.weights {
+		c.weights[addr] = w
+	}
+	return c
+}
+
+// Weight returns the capacity weight the node was added with, 0 if it isn't on the ring.
+func (r *ConsistentHashRing) Weight(nodeAddr string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.weights[nodeAddr]
+}
+
+// Shares returns the fraction of the hash space each node owns as primary.
+// Every token owns the arc between the previous token and itself.
+func (r *ConsistentHashRing) Shares() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shares := make(map[string]float64, len(r.tokens))
+	if len(r.tokens) == 1 {
+		for addr := range r.tokens {
+			shares[addr] = 1
+		}
+		return shares
+	}
+	for i, h := range r.nodes {
+		prev := r.nodes[(i+len(r.nodes)-1)%len(r.nodes)]
+		arc := h - prev // uint64 wraps around for the first token, which is what we want
+		shares[r.index[h]] += float64(arc) / math.Exp2(64)
+	}
+	return shares
+}
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultReadTimeout bounds each node a Read tries before moving on to the next one.
+const DefaultReadTimeout = 5 * time.Second
+
+// DefaultWriteTimeout bounds each upload to one node, a hung node then counts as a failed replica.
+const DefaultWriteTimeout = 30 * time.Second
+
+// Health check defaults, see healthChecker.
+const (
+	DefaultHealthInterval = 5 * time.Second
+	DefaultHealthTimeout  = 2 * time.Second
+	DefaultDownAfter      = 3
+)
+
+// nwConfig holds everything parsed out of the nw content options.
+type nwConfig struct {
+	nodes    []Member // storage nodes, written "host:port" or "host:port@weight"
+	vnodes   int      // ring tokens per unit of node weight
+	replicas int      // N, copies kept of every chunk
+	quorum   int      // W, acks a write needs
+
+	readTimeout  time.Duration // per node deadline for Read failover
+	writeTimeout time.Duration // per node deadline for an upload
+	placement    string        // ring, rendezvous or jump
+
+	healthInterval time.Duration // how often every node is probed
+	healthTimeout  time.Duration // deadline for one probe
+	downAfter      int           // failed probes in a row before a node is down
+
+	dial     DialSettings            // connection settings for every node
+	nodeDial map[string]DialSettings // nodes with their own settings after the address
+}
+
+// parseNWOptions splits the node list from the trailing key=value settings.
+// Anything starting with key=value is a setting, everything else is a node,
+// optionally followed by its own connection settings: "host:port@weight;key=value;...".
+func parseNWOptions(opts []string) (*nwConfig, error) {
+	cfg := &nwConfig{
+		vnodes:         DefaultVirtualNodes,
+		replicas:       1,
+		readTimeout:    DefaultReadTimeout,
+		writeTimeout:   DefaultWriteTimeout,
+		healthInterval: DefaultHealthInterval,
+		healthTimeout:  DefaultHealthTimeout,
+		downAfter:      DefaultDownAfter,
+		dial:           DefaultDialSettings,
+		nodeDial:       make(map[string]DialSettings),
+	}
+	nodeParams := make(map[string][]string) // applied once the global settings are all known
+	for _, opt := range opts {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		spec, params, _ := strings.Cut(opt, ";")
+		if !strings.Contains(spec, "=") {
+			node, err := parseNodeSpec(spec)
+			if err != nil {
+				return nil, err
+			}
+			cfg.nodes = append(cfg.nodes, node)
+			if params != "" {
+				nodeParams[node.Address] = strings.Split(params, ";")
+			}
+			continue
+		}
+		key, val, _ := strings.Cut(opt, "=")
+		if ok, err := parseDialSetting(&cfg.dial, key, val); ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var err error
+		switch key {
+		case "vnodes":
+			cfg.vnodes, err = parsePositiveInt(key, val)
+		case "replicas":
+			cfg.replicas, err = parsePositiveInt(key, val)
+		case "quorum":
+			cfg.quorum, err = parsePositiveInt(key, val)
+		case "read_timeout":
+			cfg.readTimeout, err = parsePositiveDuration(key, val)
+		case "write_timeout":
+			cfg.writeTimeout, err = parsePositiveDuration(key, val)
+		case "health_interval":
+			cfg.healthInterval, err = parsePositiveDuration(key, val)
+		case "health_timeout":
+			cfg.healthTimeout, err = parsePositiveDuration(key, val)
+		case "down_after":
+			cfg.downAfter, err = parsePositiveInt(key, val)
+		case "placement":
+			_, err = newPlacement(val, 1)
+			cfg.placement = val
+		default:
+			err = fmt.Errorf("unknown nw option %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for addr, params := range nodeParams {
+		settings := cfg.dial
+		for _, param := range params {
+			key, val, _ := strings.Cut(param, "=")
+			ok, err := parseDialSetting(&settings, key, val)
+			if !ok {
+				err = fmt.Errorf("unknown setting %q for node %s", key, addr)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		cfg.nodeDial[addr] = settings
+	}
+	if len(cfg.nodes) == 0 {
+		return nil, fmt.Errorf("no storage nodes in nw options")
+	}
+	if cfg.quorum == 0 {
+		// default to a majority of the replicas
+		cfg.quorum = cfg.replicas/2 + 1
+	}
+	if cfg.quorum > cfg.replicas {
+		return nil, fmt.Errorf("quorum %d is larger than replicas %d", cfg.quorum, cfg.replicas)
+	}
+	return cfg, nil
+}
+
+// parseDialSetting applies one connection setting, ok is false if key isn't one.
+func parseDialSetting(settings *DialSettings, key, val string) (ok bool, err error) {
+	switch key {
+	case "dial_timeout":
+		settings.DialTimeout, err = parsePositiveDuration(key, val)
+	case "backoff_max":
+		settings.BackoffMax, err = parsePositiveDuration(key, val)
+	case "keepalive":
+		if val == "0" || val == "off" {
+			settings.Keepalive = 0
+			return true, nil
+		}
+		settings.Keepalive, err = parsePositiveDuration(key, val)
+		if err == nil && settings.Keepalive < 10*time.Second {
+			err = fmt.Errorf("keepalive must be at least 10s, gRPC won't ping more often")
+		}
+	case "keepalive_timeout":
+		settings.KeepaliveTimeout, err = parsePositiveDuration(key, val)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// parseNodeSpec reads "host:port" or "host:port@weight", weight is 1 unless given.
+func parseNodeSpec(opt string) (Member, error) {
+	addr, w, hasWeight := strings.Cut(opt, "@")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return Member{}, fmt.Errorf("storage node %q: %v", addr, err)
+	}
+	node := Member{Address: addr, Weight: 1}
+	if hasWeight {
+		weight, err := parsePositiveInt("weight of "+addr, w)
+		if err != nil {
+			return Member{}, err
+		}
+		if weight > MaxWeight {
+			return Member{}, fmt.Errorf("weight of %s must be at most %d, got %d", addr, MaxWeight, weight)
+		}
+		node.Weight = weight
+	}
+	return node, nil
+}
+
+func parsePositiveInt(key, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, val)
+	}
+	return n, nil
+}
+
+func parsePositiveDuration(key, val string) (time.Duration, error) {
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 2s, got %q", key, val)
+	}
+	return d, nil
+}
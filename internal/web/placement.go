@@ -0,0 +1,280 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Placement decides which storage nodes hold a key.
+// NWVideoContentService only talks to this, so the algorithm can be swapped with the
+// placement= nw option: ring (default), rendezvous or jump.
+type Placement interface {
+	AddWeighted(nodeAddr string, weight int) error
+	Remove(nodeAddr string) error
+	// GetNodeForKey returns the key's owner, the first of GetNodesForKey.
+	GetNodeForKey(key string) (string, error)
+	// GetNodesForKey returns up to n distinct nodes for the key, most preferred first.
+	GetNodesForKey(key string, n int) ([]string, error)
+	// List returns every node once, in the order the membership should be saved.
+	List() []string
+	Weight(nodeAddr string) int
+	// Shares returns the fraction of keys each node owns.
+	Shares() map[string]float64
+}
+
+var (
+	_ Placement = (*ConsistentHashRing)(nil)
+	_ Placement = (*RendezvousPlacement)(nil)
+	_ Placement = (*JumpPlacement)(nil)
+)
+
+// MaxWeight caps a node's weight. The ring places vnodes tokens and jump placement a bucket
+// per unit of weight, so a typo like 1000000 would eat the memory of every web server.
+const MaxWeight = 100
+
+// ValidateWeight returns an InvalidArgument error unless weight is between 1 and MaxWeight.
+func ValidateWeight(nodeAddr string, weight int) error {
+	if weight < 1 || weight > MaxWeight {
+		return status.Errorf(codes.InvalidArgument, "node %s weight must be between 1 and %d, got %d", nodeAddr, MaxWeight, weight)
+	}
+	return nil
+}
+
+// newPlacement returns an empty placement for the placement= nw option.
+func newPlacement(strategy string, vnodes int) (Placement, error) {
+	switch strategy {
+	case "", "ring":
+		return NewVirtualNodeRing(vnodes), nil
+	case "rendezvous":
+		return NewRendezvousPlacement(), nil
+	case "jump":
+		return NewJumpPlacement(), nil
+	default:
+		return nil, fmt.Errorf("unknown placement %q, expected ring, rendezvous or jump", strategy)
+	}
+}
+
+// RendezvousPlacement is highest random weight hashing: every node scores the key and the
+// highest scores win. Adding or removing a node only moves the keys it wins or loses, and
+// there are no tokens to tune. Lookups cost one hash per node, fine for a handful of nodes.
+type RendezvousPlacement struct {
+	weights map[string]int
+	mu      sync.RWMutex
+}
+
+func NewRendezvousPlacement() *RendezvousPlacement {
+	return &RendezvousPlacement{weights: make(map[string]int)}
+}
+
+func (p *RendezvousPlacement) AddWeighted(nodeAddr string, weight int) error {
+	if err := ValidateWeight(nodeAddr, weight); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.weights[nodeAddr]; exists {
+		return status.Errorf(codes.AlreadyExists, "node %s already exists in the placement", nodeAddr)
+	}
+	p.weights[nodeAddr] = weight
+	return nil
+}
+
+func (p *RendezvousPlacement) Remove(nodeAddr string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.weights[nodeAddr]; !exists {
+		return status.Errorf(codes.NotFound, "node %s not found in the placement", nodeAddr)
+	}
+	delete(p.weights, nodeAddr)
+	return nil
+}
+
+// score is the weighted rendezvous score, -w/ln(u) with u uniform in (0,1).
+// A node with twice the weight wins twice as many keys.
+func rendezvousScore(key, nodeAddr string, weight int) float64 {
+	h := hashStringToUint64(nodeAddr + "|" + key)
+	u := (float64(h>>11) + 0.5) / (1 << 53) // top 53 bits, never exactly 0 or 1
+	return -float64(weight) / math.Log(u)
+}
+
+func (p *RendezvousPlacement) GetNodeForKey(key string) (string, error) {
+	nodes, err := p.GetNodesForKey(key, 1)
+	if err != nil {
+		return "", err
+	}
+	return nodes[0], nil
+}
+
+func (p *RendezvousPlacement) GetNodesForKey(key string, n int) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.weights) == 0 {
+		return nil, fmt.Errorf("no nodes available in the placement")
+	}
+	type scored struct {
+		addr  string
+		score float64
+	}
+	all := make([]scored, 0, len(p.weights))
+	for addr, w := range p.weights {
+		all = append(all, scored{addr, rendezvousScore(key, addr, w)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].addr < all[j].addr
+	})
+	nodes := make([]string, 0, min(n, len(all)))
+	for _, sc := range all[:min(n, len(all))] {
+		nodes = append(nodes, sc.addr)
+	}
+	return nodes, nil
+}
+
+func (p *RendezvousPlacement) List() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	nodes := make([]string, 0, len(p.weights))
+	for addr := range p.weights {
+		nodes = append(nodes, addr)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func (p *RendezvousPlacement) Weight(nodeAddr string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.weights[nodeAddr]
+}
+
+// Shares is exact for weighted rendezvous: each node's weight over the total.
+func (p *RendezvousPlacement) Shares() map[string]float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return weightShares(p.weights)
+}
+
+// JumpPlacement is Lamping and Veach's jump consistent hash over a list of buckets.
+// Each node gets weight buckets. Jump hashing can only grow or shrink at the end of the
+// bucket list, so the list keeps insertion order (and List saves it in that order).
+// Adding a node moves the minimum, removing any node but the last one moves a lot more.
+type JumpPlacement struct {
+	buckets []string // bucket number -> node address
+	weights map[string]int
+	mu      sync.RWMutex
+}
+
+func NewJumpPlacement() *JumpPlacement {
+	return &JumpPlacement{weights: make(map[string]int)}
+}
+
+func (p *JumpPlacement) AddWeighted(nodeAddr string, weight int) error {
+	if err := ValidateWeight(nodeAddr, weight); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.weights[nodeAddr]; exists {
+		return status.Errorf(codes.AlreadyExists, "node %s already exists in the placement", nodeAddr)
+	}
+	p.weights[nodeAddr] = weight
+	for i := 0; i < weight; i++ {
+		p.buckets = append(p.buckets, nodeAddr)
+	}
+	return nil
+}
+
+func (p *JumpPlacement) Remove(nodeAddr string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.weights[nodeAddr]; !exists {
+		return status.Errorf(codes.NotFound, "node %s not found in the placement", nodeAddr)
+	}
+	delete(p.weights, nodeAddr)
+	p.buckets = slices.DeleteFunc(p.buckets, func(addr string) bool { return addr == nodeAddr })
+	return nil
+}
+
+// jumpHash maps key to a bucket in [0, buckets), straight from the paper.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (p *JumpPlacement) GetNodeForKey(key string) (string, error) {
+	nodes, err := p.GetNodesForKey(key, 1)
+	if err != nil {
+		return "", err
+	}
+	return nodes[0], nil
+}
+
+// GetNodesForKey starts at the key's bucket and walks up the bucket list for the replicas.
+func (p *JumpPlacement) GetNodesForKey(key string, n int) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.buckets) == 0 {
+		return nil, fmt.Errorf("no nodes available in the placement")
+	}
+	n = min(n, len(p.weights))
+	start := jumpHash(hashStringToUint64(key), len(p.buckets))
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(p.buckets) && len(nodes) < n; i++ {
+		addr := p.buckets[(start+i)%len(p.buckets)]
+		if !slices.Contains(nodes, addr) {
+			nodes = append(nodes, addr)
+		}
+	}
+	return nodes, nil
+}
+
+// List returns the nodes in bucket order, which is the order they must be re-added in.
+func (p *JumpPlacement) List() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	nodes := make([]string, 0, len(p.weights))
+	for _, addr := range p.buckets {
+		if !slices.Contains(nodes, addr) {
+			nodes = append(nodes, addr)
+		}
+	}
+	return nodes
+}
+
+func (p *JumpPlacement) Weight(nodeAddr string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.weights[nodeAddr]
+}
+
+// Shares is each node's bucket count over the total, jump hashing spreads keys evenly over buckets.
+func (p *JumpPlacement) Shares() map[string]float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return weightShares(p.weights)
+}
+
+func weightShares(weights map[string]int) map[string]float64 {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	shares := make(map[string]float64, len(weights))
+	for addr, w := range weights {
+		shares[addr] = float64(w) / float64(total)
+	}
+	return shares
+}
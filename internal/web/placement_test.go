@@ -0,0 +1,151 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestJumpHash(t *testing.T) {
+	for key := uint64(0); key < 2000; key++ {
+		k := hashStringToUint64(fmt.Sprint(key))
+		prev := jumpHash(k, 1)
+		if prev != 0 {
+			t.Fatalf("jumpHash(%d, 1) = %d, want 0", k, prev)
+		}
+		for buckets := 2; buckets <= 20; buckets++ {
+			b := jumpHash(k, buckets)
+			if b != jumpHash(k, buckets) {
+				t.Fatalf("jumpHash(%d, %d) isn't deterministic", k, buckets)
+			}
+			if b < 0 || b >= buckets {
+				t.Fatalf("jumpHash(%d, %d) = %d, out of range", k, buckets, b)
+			}
+			// growing the bucket list only ever moves a key into the new bucket
+			if b != prev && b != buckets-1 {
+				t.Fatalf("jumpHash(%d, %d) = %d, was %d with one bucket less", k, buckets, b, prev)
+			}
+			prev = b
+		}
+	}
+}
+
+func TestPlacement(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() Placement
+		// adding a node leaves every replica set as it was or swaps the new node in,
+		// jump placement only keeps that promise for the first owner
+		stableReplicas bool
+	}{
+		{"ring", func() Placement { return NewVirtualNodeRing(160) }, true},
+		{"rendezvous", func() Placement { return NewRendezvousPlacement() }, true},
+		{"jump", func() Placement { return NewJumpPlacement() }, false},
+	}
+	weights := map[string]int{"a:1": 1, "b:1": 1, "c:1": 2, "d:1": 4}
+	order := []string{"a:1", "b:1", "c:1", "d:1"}
+	build := func(t *testing.T, newPlacement func() Placement, nodes []string) Placement {
+		t.Helper()
+		p := newPlacement()
+		for _, addr := range nodes {
+			if err := p.AddWeighted(addr, weights[addr]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return p
+	}
+	const keys = 20000
+	key := func(i int) string { return fmt.Sprintf("v%d/chunk-%05d.m4s", i%50, i) }
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/deterministic", func(t *testing.T) {
+			p1, p2 := build(t, tt.new, order), build(t, tt.new, order)
+			for i := 0; i < 1000; i++ {
+				n1, err := p1.GetNodesForKey(key(i), 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n2, err := p2.GetNodesForKey(key(i), 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(n1, n2) {
+					t.Fatalf("%s: %v from one placement, %v from an identical one", key(i), n1, n2)
+				}
+			}
+		})
+
+		t.Run(tt.name+"/distinct owners", func(t *testing.T) {
+			p := build(t, tt.new, order)
+			for n := 1; n <= len(order)+1; n++ {
+				for i := 0; i < 500; i++ {
+					nodes, err := p.GetNodesForKey(key(i), n)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if want := min(n, len(order)); len(nodes) != want {
+						t.Fatalf("GetNodesForKey(%s, %d) = %v, want %d nodes", key(i), n, nodes, want)
+					}
+					if len(slices.Compact(slices.Sorted(slices.Values(nodes)))) != len(nodes) {
+						t.Fatalf("GetNodesForKey(%s, %d) = %v, has duplicates", key(i), n, nodes)
+					}
+					if owner, _ := p.GetNodeForKey(key(i)); owner != nodes[0] {
+						t.Fatalf("GetNodeForKey(%s) = %s, GetNodesForKey starts with %s", key(i), owner, nodes[0])
+					}
+				}
+			}
+		})
+
+		t.Run(tt.name+"/weighted share", func(t *testing.T) {
+			p := build(t, tt.new, order)
+			owned := make(map[string]int)
+			for i := 0; i < keys; i++ {
+				owner, err := p.GetNodeForKey(key(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				owned[owner]++
+			}
+			for addr, w := range weights {
+				want := float64(w) / 8
+				got := float64(owned[addr]) / keys
+				if math.Abs(got-want) > 0.05 {
+					t.Errorf("%s of weight %d owns %.3f of the keys, want about %.3f", addr, w, got, want)
+				}
+			}
+		})
+
+		t.Run(tt.name+"/add moves keys only to the new node", func(t *testing.T) {
+			before, after := build(t, tt.new, order[:3]), build(t, tt.new, order)
+			moved := 0
+			for i := 0; i < keys; i++ {
+				old, err := before.GetNodesForKey(key(i), 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				now, err := after.GetNodesForKey(key(i), 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if now[0] != old[0] {
+					moved++
+					if now[0] != "d:1" {
+						t.Fatalf("%s moved from %s to %s, not to the new node", key(i), old[0], now[0])
+					}
+				}
+				if !tt.stableReplicas {
+					continue
+				}
+				for _, addr := range now {
+					if addr != "d:1" && !slices.Contains(old, addr) {
+						t.Fatalf("%s was on %v, now on %v", key(i), old, now)
+					}
+				}
+			}
+			if moved == 0 {
+				t.Fatalf("no key moved to the new node")
+			}
+		})
+	}
+}
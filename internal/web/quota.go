@@ -0,0 +1,93 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ErrFull is returned by writes that would take the service past its quota or into the
+// free space it has to leave on the disk.
+var ErrFull = errors.New("storage full")
+
+// countUsage adds up what is stored already, writes and deletes keep it current from there.
+func (s *FSVideoContentService) countUsage() {
+	_, sizes, err := s.ListAllSizes()
+	if err != nil {
+		log.Printf("Failed to add up the files under %s, the quota starts from 0: %v", s.baseDir, err)
+		return
+	}
+	var used int64
+	for _, size := range sizes {
+		used += size
+	}
+	s.used.Store(used)
+}
+
+// quotaReader charges what it reads to the service's usage and fails with ErrFull
+// once that goes over the quota, or over what the disk can take above the reserve.
+// Free space is only measured when a write starts, so every write in progress also counts
+// its bytes in s.pending and checks the total of them against the room: two uploads that
+// each fit can't eat into the reserve together. Bytes of other writes that were on disk
+// already when free space was measured are counted twice, which errs on the safe side.
+type quotaReader struct {
+	s    *FSVideoContentService
+	r    io.Reader
+	room int64 // bytes the disk could take above the reserve when the write started, -1 if no limit
+	n    int64 // bytes charged so far
+	pend int64 // bytes counted in s.pending, given back by done
+}
+
+// newQuotaReader starts a write, failing right away if there is no room left for anything.
+func (s *FSVideoContentService) newQuotaReader(r io.Reader) (*quotaReader, error) {
+	q := &quotaReader{s: s, r: r, room: -1}
+	if quota := s.opts.Quota; quota > 0 && s.used.Load() >= quota {
+		return nil, fmt.Errorf("%w: %d of %d bytes quota used", ErrFull, s.used.Load(), quota)
+	}
+	if s.opts.Reserve > 0 {
+		free, _, err := s.DiskSpace()
+		if err != nil {
+			log.Printf("Free space unknown, not enforcing the reserve: %v", err)
+			return q, nil
+		}
+		q.room = free - s.opts.Reserve
+		if pending := s.pending.Load(); q.room-pending <= 0 {
+			return nil, fmt.Errorf("%w: %d bytes free, %d of them taken by writes in progress, %d must stay free",
+				ErrFull, free, pending, s.opts.Reserve)
+		}
+	}
+	return q, nil
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	q.n += int64(n)
+	used := q.s.used.Add(int64(n))
+	if quota := q.s.opts.Quota; quota > 0 && used > quota {
+		return 0, fmt.Errorf("%w: write would take it past the %d bytes quota", ErrFull, quota)
+	}
+	if q.room >= 0 {
+		q.pend += int64(n)
+		if pending := q.s.pending.Add(int64(n)); pending > q.room {
+			return 0, fmt.Errorf("%w: write would leave less than %d bytes free", ErrFull, q.s.opts.Reserve)
+		}
+	}
+	return n, err
+}
+
+// release gives back what a write that didn't go through charged.
+func (q *quotaReader) release() {
+	q.s.used.Add(-q.n)
+	q.n = 0
+}
+
+// done ends the write's share of the pending bytes, whether it went through or not.
+// What it committed is on disk by now and shows in the free space the next write measures.
+func (q *quotaReader) done() {
+	q.s.pending.Add(-q.pend)
+	q.pend = 0
+}
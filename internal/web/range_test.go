@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		offset, length int64
+		ok             bool
+	}{
+		{"bytes=0-99", 0, 100, true},
+		{"bytes=100-199", 100, 100, true},
+		{"bytes=5-5", 5, 1, true},
+		{"bytes=100-", 100, 0, true},
+		{"bytes=-500", -500, 0, true},
+		{"bytes= 10-19", 10, 10, true},
+		{"bytes=0-99,200-299", 0, 0, false},
+		{"bytes=-0", 0, 0, false},
+		{"bytes=20-10", 0, 0, false},
+		{"bytes=-5-10", 0, 0, false},
+		{"bytes=a-b", 0, 0, false},
+		{"bytes=10", 0, 0, false},
+		{"items=0-99", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			offset, length, ok := parseRange(tt.header)
+			if ok != tt.ok || offset != tt.offset || length != tt.length {
+				t.Fatalf("parseRange(%q) = %d, %d, %v, want %d, %d, %v",
+					tt.header, offset, length, ok, tt.offset, tt.length, tt.ok)
+			}
+		})
+	}
+}
+
+func TestClampRange(t *testing.T) {
+	tests := []struct {
+		name                 string
+		size, offset, length int64
+		start, n             int64
+	}{
+		{"inside", 1000, 100, 100, 100, 100},
+		{"to the end", 1000, 100, 0, 100, 900},
+		{"end past the size", 1000, 900, 500, 900, 100},
+		{"suffix", 1000, -100, 0, 900, 100},
+		{"suffix longer than the file", 1000, -5000, 0, 0, 1000},
+		{"start at the end", 1000, 1000, 10, 1000, 0},
+		{"start past the end", 1000, 5000, 10, 1000, 0},
+		{"empty file", 0, 0, 0, 0, 0},
+		{"suffix of an empty file", 0, -10, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, n := clampRange(tt.size, tt.offset, tt.length)
+			if start != tt.start || n != tt.n {
+				t.Fatalf("clampRange(%d, %d, %d) = %d, %d, want %d, %d",
+					tt.size, tt.offset, tt.length, start, n, tt.start, tt.n)
+			}
+		})
+	}
+}
+
+// bytesReader is a RangeReader over one file held in memory.
+type bytesReader []byte
+
+func (b bytesReader) ReadRange(videoId, filename string, offset, length int64) ([]byte, int64, error) {
+	start, n := clampRange(int64(len(b)), offset, length)
+	return b[start : start+n], int64(len(b)), nil
+}
+
+func TestServeRange(t *testing.T) {
+	file := make(bytesReader, 1000)
+	tests := []struct {
+		header       string
+		status       int
+		contentRange string
+	}{
+		{"bytes=0-99", http.StatusPartialContent, "bytes 0-99/1000"},
+		{"bytes=900-", http.StatusPartialContent, "bytes 900-999/1000"},
+		{"bytes=-100", http.StatusPartialContent, "bytes 900-999/1000"},
+		{"bytes=900-5000", http.StatusPartialContent, "bytes 900-999/1000"},
+		{"bytes=1000-1099", http.StatusRequestedRangeNotSatisfiable, "bytes */1000"},
+		{"bytes=5000-", http.StatusRequestedRangeNotSatisfiable, "bytes */1000"},
+	}
+	s := &server{}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			offset, length, ok := parseRange(tt.header)
+			if !ok {
+				t.Fatalf("parseRange(%q) failed", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			s.serveRange(rec, file, "v1", "chunk.m4s", "video/mp4", offset, length)
+			if rec.Code != tt.status || rec.Header().Get("Content-Range") != tt.contentRange {
+				t.Fatalf("%s: got %d %q, want %d %q", tt.header, rec.Code, rec.Header().Get("Content-Range"), tt.status, tt.contentRange)
+			}
+		})
+	}
+}
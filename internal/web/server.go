@@ -5,6 +5,7 @@ package web
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"log"
@@ -15,6 +16,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -176,6 +178,12 @@ func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		// for lab 8, we will use a network content service
 		return s.contentService.Write(videoId, relPath, chunk)
 	})
+	if errors.Is(err, ErrNodeFull) || errors.Is(err, ErrFull) {
+		// no node on the ring had room for some chunk, retrying won't help until space is freed
+		log.Printf("upload %s: %v", videoId, err)
+		http.Error(w, "storage node full", http.StatusInsufficientStorage)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to store content", http.StatusInternalServerError)
 		return
@@ -227,11 +235,6 @@ func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 	// how does the content service know which file to read?
 	// this isclient directed. So those details are on the client side
 
-	data, err := s.contentService.Read(videoId, filename)
-	if err != nil {
-		http.Error(w, "could not read content", http.StatusInternalServerError)
-		return
-	}
 	// Set a reasonable Content-Type based on file extension
 	mimeType := mime.TypeByExtension(filepath.Ext(filename))
 	if mimeType == "" {
@@ -241,11 +244,80 @@ func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 			mimeType = "application/octet-stream"
 		}
 	}
+
+	// range requests only fetch the bytes asked for, if the content service can do that
+	if rr, ok := s.contentService.(RangeReader); ok {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if offset, length, ok := parseRange(r.Header.Get("Range")); ok {
+			s.serveRange(w, rr, videoId, filename, mimeType, offset, length)
+			return
+		}
+	}
+
+	data, err := s.contentService.Read(videoId, filename)
+	if err != nil {
+		http.Error(w, "could not read content", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", mimeType)
 	// Write the raw bytes
 	w.Write(data)
 }
 
+// serveRange answers a range request with 206 and the part the content service returned,
+// which may end before the range asked for, or 416 if the range starts past the end.
+func (s *server) serveRange(w http.ResponseWriter, rr RangeReader, videoId, filename, mimeType string, offset, length int64) {
+	data, size, err := rr.ReadRange(videoId, filename, offset, length)
+	if err != nil {
+		http.Error(w, "could not read content", http.StatusInternalServerError)
+		return
+	}
+	start, _ := clampRange(size, offset, length)
+	if len(data) == 0 {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, "range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+int64(len(data))-1, size))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data)
+}
+
+// parseRange reads a single "bytes=first-last", "bytes=first-" or "bytes=-suffix" range
+// into a ReadRange offset and length. Anything else, multiple ranges included, isn't
+// supported and the whole file is sent instead, which HTTP allows.
+func parseRange(header string) (offset, length int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		return -n, 0, true // the last n bytes
+	}
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	if last == "" {
+		return offset, 0, true
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < offset {
+		return 0, 0, false
+	}
+	return offset, end - offset + 1, true
+}
+
 func encodeVideo(videoFile *os.File, outputPath string) error {
 	manifest := filepath.Join(outputPath, "manifest.mpd")
 	cmd := exec.Command("ffmpeg",
@@ -4,6 +4,7 @@ package web
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -31,6 +32,15 @@ func NewSQLiteVideoMetadataService(dbPath string) (*SQLiteVideoMetadataService,
 	schema := `CREATE TABLE IF NOT EXISTS videos (
 		id TEXT PRIMARY KEY,
 		uploaded_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS ring_members (
+		address TEXT PRIMARY KEY,
+		weight INTEGER NOT NULL,
+		drained INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS migrations (
+		id TEXT PRIMARY KEY,
+		record TEXT NOT NULL
 	);`
 	if _, err := db.Exec(schema); err != nil {
 		db.Close()
@@ -99,3 +109,87 @@ func (s *SQLiteVideoMetadataService) Read(id string) (*VideoMetadata, error) {
 // Uncomment the following line to ensure SQLiteVideoMetadataService implements VideoMetadataService
 // means that SQLiteVideoMetadataService implements all methods of the VideoMetadataService interface
 var _ VideoMetadataService = (*SQLiteVideoMetadataService)(nil)
+
+// ring_members only ever holds the current membership, an empty table means it was never saved.
+// Rows come back in insertion order, jump placement depends on it.
+var _ MembershipStore = (*SQLiteVideoMetadataService)(nil)
+
+func (s *SQLiteVideoMetadataService) LoadMembership() ([]Member, bool, error) {
+	rows, err := s.db.Query(`SELECT address, weight, drained FROM ring_members ORDER BY rowid`)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	var members []Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.Address, &m.Weight, &m.Drained); err != nil {
+			return nil, false, err
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	return members, len(members) > 0, nil
+}
+
+// SaveMembership replaces the stored membership in one transaction.
+func (s *SQLiteVideoMetadataService) SaveMembership(members []Member) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if _, err := tx.Exec(`DELETE FROM ring_members`); err != nil {
+		return err
+	}
+	for _, m := range members {
+		if _, err := tx.Exec(`INSERT INTO ring_members (address, weight, drained) VALUES (?, ?, ?)`, m.Address, m.Weight, m.Drained); err != nil {
+			return fmt.Errorf("sqlite insert failed: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrations holds one JSON MigrationRecord per unfinished migration job.
+var _ MigrationJournal = (*SQLiteVideoMetadataService)(nil)
+
+func (s *SQLiteVideoMetadataService) SaveMigration(rec MigrationRecord) error {
+	val, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO migrations (id, record) VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET record = excluded.record`, rec.ID, string(val))
+	if err != nil {
+		return fmt.Errorf("sqlite insert failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteVideoMetadataService) LoadMigrations() ([]MigrationRecord, error) {
+	rows, err := s.db.Query(`SELECT record FROM migrations ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []MigrationRecord
+	for rows.Next() {
+		var val string
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+		var rec MigrationRecord
+		if err := json.Unmarshal([]byte(val), &rec); err != nil {
+			return nil, fmt.Errorf("decode migration record: %w", err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+func (s *SQLiteVideoMetadataService) DeleteMigration(id string) error {
+	_, err := s.db.Exec(`DELETE FROM migrations WHERE id = ?`, id)
+	return err
+}